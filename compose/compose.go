@@ -0,0 +1,311 @@
+package compose
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strconv"
+)
+
+// Project is the typed representation of a docker-compose.yml file. Keys which are not modelled explicitly are kept
+// in the Extra maps, so that a file can be read, modified and written again without losing information.
+type Project struct {
+	Services map[string]*Service    `yaml:"services,omitempty"`
+	Volumes  map[string]*Volume     `yaml:"volumes,omitempty"`
+	Networks map[string]*Network    `yaml:"networks,omitempty"`
+	Extra    map[string]interface{} `yaml:",inline"`
+
+	keys         []string
+	serviceNames []string
+	root         *yaml.Node
+}
+
+// Position is a location in the parsed file. Line and column start at 1, zero values mean that the location is unknown.
+type Position struct {
+	Line   int
+	Column int
+}
+
+type Service struct {
+	Image         string                 `yaml:"image,omitempty"`
+	ContainerName string                 `yaml:"container_name,omitempty"`
+	Ports         []Port                 `yaml:"ports,omitempty"`
+	Volumes       []ServiceVolume        `yaml:"volumes,omitempty"`
+	DependsOn     DependsOn              `yaml:"depends_on,omitempty"`
+	Environment   Environment            `yaml:"environment,omitempty"`
+	Deploy        *Deploy                `yaml:"deploy,omitempty"`
+	Tmpfs         StringOrList           `yaml:"tmpfs,omitempty"`
+	Tty           bool                   `yaml:"tty,omitempty"`
+	User          string                 `yaml:"user,omitempty"`
+	Command       StringOrList           `yaml:"command,omitempty"`
+	Entrypoint    StringOrList           `yaml:"entrypoint,omitempty"`
+	Networks      NetworkList            `yaml:"networks,omitempty"`
+	Restart       string                 `yaml:"restart,omitempty"`
+	CapDrop       []string               `yaml:"cap_drop,omitempty"`
+	CapAdd        []string               `yaml:"cap_add,omitempty"`
+	SecurityOpt   []string               `yaml:"security_opt,omitempty"`
+	ReadOnly      bool                   `yaml:"read_only,omitempty"`
+	Logging       *Logging               `yaml:"logging,omitempty"`
+	Labels        Labels                 `yaml:"labels,omitempty"`
+	Healthcheck   *Healthcheck           `yaml:"healthcheck,omitempty"`
+	Extra         map[string]interface{} `yaml:",inline"`
+
+	keys []string
+}
+
+// Healthcheck tells docker how to check whether a service is ready. Durations are kept as written, like "1m30s".
+// Retries is nil when it is not set, so that it can be told apart from 'retries: 0'.
+type Healthcheck struct {
+	Test          StringOrList `yaml:"test,omitempty"`
+	Interval      string       `yaml:"interval,omitempty"`
+	Timeout       string       `yaml:"timeout,omitempty"`
+	Retries       *int         `yaml:"retries,omitempty"`
+	StartPeriod   string       `yaml:"start_period,omitempty"`
+	StartInterval string       `yaml:"start_interval,omitempty"`
+	Disable       bool         `yaml:"disable,omitempty"`
+}
+
+type Logging struct {
+	Driver  string            `yaml:"driver,omitempty"`
+	Options map[string]string `yaml:"options,omitempty"`
+}
+
+type Deploy struct {
+	Resources *Resources             `yaml:"resources,omitempty"`
+	Extra     map[string]interface{} `yaml:",inline"`
+}
+
+type Resources struct {
+	Limits       *ResourceValues        `yaml:"limits,omitempty"`
+	Reservations *ResourceValues        `yaml:"reservations,omitempty"`
+	Extra        map[string]interface{} `yaml:",inline"`
+}
+
+type ResourceValues struct {
+	Cpus    string                   `yaml:"cpus,omitempty"`
+	Memory  string                   `yaml:"memory,omitempty"`
+	Pids    int64                    `yaml:"pids,omitempty"`
+	Devices []map[string]interface{} `yaml:"devices,omitempty"`
+}
+
+type Volume struct {
+	Name       string                 `yaml:"name,omitempty"`
+	Driver     string                 `yaml:"driver,omitempty"`
+	DriverOpts map[string]string      `yaml:"driver_opts,omitempty"`
+	External   bool                   `yaml:"external,omitempty"`
+	Labels     map[string]string      `yaml:"labels,omitempty"`
+	Extra      map[string]interface{} `yaml:",inline"`
+}
+
+// IsEmpty tells whether the volume is declared without any sub-keywords.
+func (v *Volume) IsEmpty() bool {
+	return v == nil || (v.Name == "" && v.Driver == "" && len(v.DriverOpts) == 0 && !v.External && len(v.Labels) == 0 && len(v.Extra) == 0)
+}
+
+type Network struct {
+	Name     string                 `yaml:"name,omitempty"`
+	Driver   string                 `yaml:"driver,omitempty"`
+	External bool                   `yaml:"external,omitempty"`
+	Extra    map[string]interface{} `yaml:",inline"`
+}
+
+// Parse reads the content of a docker-compose.yml file. Values of unexpected types lead to an error.
+func Parse(data []byte) (*Project, error) {
+	project := &Project{}
+	if err := yaml.Unmarshal(data, project); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func Load(filePath string) (*Project, error) {
+	data, err := os.ReadFile(filePath) // #nosec G304 (CWE-22): Potential file inclusion via variable; no problem since it is called internally
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func (p *Project) Marshal() ([]byte, error) {
+	return yaml.Marshal(p)
+}
+
+func (p *Project) Save(filePath string) error {
+	data, err := p.Marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0600)
+}
+
+// Keys returns the top-level keys of the parsed file in document order.
+func (p *Project) Keys() []string {
+	return p.keys
+}
+
+// IsEmpty tells whether the parsed file did not contain any keys.
+func (p *Project) IsEmpty() bool {
+	return len(p.keys) == 0
+}
+
+// ServiceNames returns the names of the services in document order. Services added after parsing are appended in alphabetical order.
+func (p *Project) ServiceNames() []string {
+	names := make([]string, 0, len(p.Services))
+	known := make(map[string]bool)
+	for _, name := range p.serviceNames {
+		if _, ok := p.Services[name]; ok {
+			names = append(names, name)
+			known[name] = true
+		}
+	}
+	for _, name := range sortedKeys(p.Services) {
+		if !known[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (p *Project) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: docker-compose.yml must be a map", node.Line)
+	}
+	servicesNode := mappingValue(node, "services")
+	if servicesNode != nil {
+		if servicesNode.Kind != yaml.MappingNode {
+			return fmt.Errorf("invalid 'services' section in docker-compose.yml")
+		}
+		for _, entry := range MappingEntries(servicesNode) {
+			if entry[1].Kind != yaml.MappingNode {
+				return fmt.Errorf("invalid service definition for service %v", entry[0].Value)
+			}
+		}
+	}
+	if volumesNode := mappingValue(node, "volumes"); volumesNode != nil && volumesNode.Kind != yaml.MappingNode && !isNull(volumesNode) {
+		return fmt.Errorf("invalid 'volumes' section in docker-compose.yml")
+	}
+
+	type plain Project
+	if err := node.Decode((*plain)(p)); err != nil {
+		return err
+	}
+	p.keys = mappingKeys(node)
+	p.root = node
+	if servicesNode != nil {
+		p.serviceNames = mappingKeys(servicesNode)
+	}
+	return nil
+}
+
+// Position returns the location of the element at the given path, like ("services", "app", "ports", "0"). Map entries
+// are located at their key, list entries by their index. If the path cannot be fully resolved, the location of the
+// deepest element found is returned.
+func (p *Project) Position(path ...string) Position {
+	if p.root == nil {
+		return Position{}
+	}
+	position := Position{Line: p.root.Line, Column: p.root.Column}
+	node := p.root
+	for _, element := range path {
+		var keyNode, valueNode *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == element {
+					keyNode, valueNode = node.Content[i], node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(element)
+			if err == nil && index >= 0 && index < len(node.Content) {
+				keyNode, valueNode = node.Content[index], node.Content[index]
+			}
+		}
+		if keyNode == nil {
+			return position
+		}
+		position = Position{Line: keyNode.Line, Column: keyNode.Column}
+		node = valueNode
+	}
+	return position
+}
+
+// Keys returns the keys the service was declared with in document order.
+func (s *Service) Keys() []string {
+	return s.keys
+}
+
+func (s *Service) UnmarshalYAML(node *yaml.Node) error {
+	type plain Service
+	if err := node.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	s.keys = mappingKeys(node)
+	return nil
+}
+
+func mappingKeys(node *yaml.Node) []string {
+	var keys []string
+	for _, entry := range MappingEntries(node) {
+		keys = append(keys, entry[0].Value)
+	}
+	return keys
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	value, _ := findEntry(MappingEntries(node), key)
+	return value
+}
+
+// ResolveAlias returns the node an alias like '*base' refers to, or the node itself if it is no alias.
+func ResolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// MappingEntries returns the key and value nodes of a mapping, including the entries merged with '<<', like yaml.v3
+// does when decoding into a map. Explicit entries take precedence over merged ones. Values are resolved with
+// ResolveAlias.
+func MappingEntries(node *yaml.Node) [][2]*yaml.Node {
+	node = ResolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	var entries, merged [][2]*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], ResolveAlias(node.Content[i+1])
+		if key.ShortTag() != "!!merge" {
+			entries = append(entries, [2]*yaml.Node{key, value})
+			continue
+		}
+		sources := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			sources = value.Content
+		}
+		for _, source := range sources {
+			merged = append(merged, MappingEntries(source)...)
+		}
+	}
+	for _, entry := range merged {
+		if _, found := findEntry(entries, entry[0].Value); !found {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func findEntry(entries [][2]*yaml.Node, key string) (*yaml.Node, bool) {
+	for _, entry := range entries {
+		if entry[0].Value == key {
+			return entry[1], true
+		}
+	}
+	return nil, false
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
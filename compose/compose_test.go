@@ -0,0 +1,227 @@
+package compose
+
+import (
+	"github.com/ocelot-cloud/shared/assert"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var sampleCompose = `
+services:
+  gitea:
+    image: gitea/gitea:1.20.2
+    container_name: samplemaintainer_gitea_gitea
+    ports:
+      - "2222:22"
+      - 8080
+      - target: 3000
+        published: "3000"
+        protocol: tcp
+    volumes:
+      - samplemaintainer_gitea_data:/data
+      - type: volume
+        source: samplemaintainer_gitea_config
+        target: /config
+        read_only: true
+    environment:
+      - USER_UID=1000
+      - EMPTY=
+      - NO_VALUE
+    depends_on:
+      - giteadb
+    command: --
+    entrypoint: ["/bin/sh", "-c"]
+    deploy:
+      resources:
+        limits:
+          cpus: "0.5"
+          memory: 512M
+  giteadb:
+    image: mariadb:10.5
+    container_name: samplemaintainer_gitea_db
+    environment:
+      MARIADB_USER: gitea
+      MARIADB_AUTO_UPGRADE: true
+      UNSET:
+    depends_on:
+      other:
+        condition: service_healthy
+    privileged: true
+volumes:
+  samplemaintainer_gitea_data:
+  samplemaintainer_gitea_config:
+    name: config
+`
+
+func TestParse(t *testing.T) {
+	project, err := Parse([]byte(sampleCompose))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"services", "volumes"}, project.Keys())
+	assert.Equal(t, []string{"gitea", "giteadb"}, project.ServiceNames())
+
+	gitea := project.Services["gitea"]
+	assert.Equal(t, []string{"image", "container_name", "ports", "volumes", "environment", "depends_on", "command", "entrypoint", "deploy"}, gitea.Keys())
+	assert.Equal(t, "gitea/gitea:1.20.2", gitea.Image)
+	assert.Equal(t, 3, len(gitea.Ports))
+	assert.Equal(t, "2222:22", gitea.Ports[0].Short)
+	assert.Equal(t, "8080", gitea.Ports[1].Short)
+	assert.True(t, gitea.Ports[2].IsLongSyntax())
+	assert.Equal(t, 3000, gitea.Ports[2].Target)
+	assert.Equal(t, "3000", gitea.Ports[2].Published)
+
+	assert.Equal(t, "samplemaintainer_gitea_data:/data", gitea.Volumes[0].Short)
+	assert.True(t, gitea.Volumes[1].IsLongSyntax())
+	assert.Equal(t, "samplemaintainer_gitea_config", gitea.Volumes[1].Source)
+	assert.True(t, gitea.Volumes[1].ReadOnly)
+
+	assert.False(t, gitea.Environment.MapSyntax)
+	value, found := gitea.Environment.Get("USER_UID")
+	assert.True(t, found)
+	assert.Equal(t, "1000", value)
+	value, found = gitea.Environment.Get("EMPTY")
+	assert.True(t, found)
+	assert.Equal(t, "", value)
+	_, found = gitea.Environment.Get("NO_VALUE")
+	assert.False(t, found)
+
+	assert.Equal(t, []string{"giteadb"}, gitea.DependsOn.Services)
+	assert.Equal(t, StringOrList{Values: []string{"--"}}, gitea.Command)
+	assert.Equal(t, StringOrList{Values: []string{"/bin/sh", "-c"}, IsList: true}, gitea.Entrypoint)
+	assert.Equal(t, "512M", gitea.Deploy.Resources.Limits.Memory)
+
+	giteadb := project.Services["giteadb"]
+	assert.True(t, giteadb.Environment.MapSyntax)
+	value, _ = giteadb.Environment.Get("MARIADB_AUTO_UPGRADE")
+	assert.Equal(t, "true", value)
+	assert.Equal(t, "service_healthy", giteadb.DependsOn.Conditions["other"].Condition)
+	assert.Equal(t, true, giteadb.Extra["privileged"])
+
+	assert.True(t, project.Volumes["samplemaintainer_gitea_data"].IsEmpty())
+	assert.False(t, project.Volumes["samplemaintainer_gitea_config"].IsEmpty())
+}
+
+func TestMarshalKeepsContent(t *testing.T) {
+	project, err := Parse([]byte(sampleCompose))
+	assert.Nil(t, err)
+	output, err := project.Marshal()
+	assert.Nil(t, err)
+
+	var expected, actual map[string]interface{}
+	assert.Nil(t, yaml.Unmarshal([]byte(sampleCompose), &expected))
+	assert.Nil(t, yaml.Unmarshal(output, &actual))
+	expectedServices := expected["services"].(map[string]interface{})
+	expectedServices["gitea"].(map[string]interface{})["ports"].([]interface{})[1] = "8080"
+	expectedServices["giteadb"].(map[string]interface{})["environment"].(map[string]interface{})["MARIADB_AUTO_UPGRADE"] = "true"
+	assert.True(t, reflect.DeepEqual(expected, actual), string(output))
+}
+
+func TestParseMergeKeys(t *testing.T) {
+	content := `
+x-base: &base
+  image: mariadb:10.5
+  restart: always
+services:
+  db:
+    <<: *base
+    container_name: samplemaintainer_gitea_db
+    restart: "no"
+  copy: *base
+`
+	project, err := Parse([]byte(content))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"db", "copy"}, project.ServiceNames())
+	assert.Equal(t, []string{"container_name", "restart", "image"}, project.Services["db"].Keys())
+	assert.Equal(t, "mariadb:10.5", project.Services["db"].Image)
+	assert.Equal(t, []string{"image", "restart"}, project.Services["copy"].Keys())
+}
+
+func TestParseEmptyFile(t *testing.T) {
+	project, err := Parse([]byte(""))
+	assert.Nil(t, err)
+	assert.True(t, project.IsEmpty())
+	assert.Equal(t, 0, len(project.ServiceNames()))
+}
+
+func TestParseMalformedFiles(t *testing.T) {
+	testCases := []struct {
+		content       string
+		expectedError string
+	}{
+		{"hello", "line 1: docker-compose.yml must be a map"},
+		{"services: hello", "invalid 'services' section in docker-compose.yml"},
+		{"services:\n  app:", "invalid service definition for service app"},
+		{"volumes: [a]", "invalid 'volumes' section in docker-compose.yml"},
+		{"services:\n  app:\n    image: [a]", "cannot unmarshal !!seq into string"},
+		{"services:\n  app:\n    ports:\n      web: 80", "cannot unmarshal !!map into []compose.Port"},
+		{"services:\n  app:\n    ports:\n      - [80]", "line 4: port must be a string or a map"},
+		{"services:\n  app:\n    ports:\n      - target: abc", "cannot unmarshal !!str `abc` into int"},
+		{"services:\n  app:\n    volumes:\n      - [a]", "line 4: volume must be a string or a map"},
+		{"services:\n  app:\n    environment: abc", "line 3: environment must be a list or a map"},
+		{"services:\n  app:\n    environment:\n      - [a]", "line 4: environment list entries must be strings"},
+		{"services:\n  app:\n    environment:\n      A: [b]", "line 4: environment values must be strings, numbers or booleans"},
+		{"services:\n  app:\n    command:\n      a: b", "line 4: value must be a string or a list of strings"},
+		{"services:\n  app:\n    depends_on: db", "line 3: 'depends_on' must be a list or a map"},
+		{"services:\n  app:\n    networks: net", "line 3: 'networks' must be a list or a map"},
+		{"services:\n  app:\n    deploy: 1", "cannot unmarshal !!int `1` into compose.Deploy"},
+	}
+
+	for _, tc := range testCases {
+		_, err := Parse([]byte(tc.content))
+		assert.NotNil(t, err, tc.content)
+		if err != nil {
+			assert.True(t, strings.Contains(err.Error(), tc.expectedError), err.Error())
+		}
+	}
+}
+
+func TestEnvironmentSet(t *testing.T) {
+	environment := Environment{}
+	environment.Set("A", "1")
+	environment.Set("B", "2")
+	environment.Set("A", "3")
+	output, err := yaml.Marshal(environment)
+	assert.Nil(t, err)
+	assert.Equal(t, "- A=3\n- B=2\n", string(output))
+
+	environment.MapSyntax = true
+	output, err = yaml.Marshal(environment)
+	assert.Nil(t, err)
+	assert.Equal(t, "A: \"3\"\nB: \"2\"\n", string(output))
+}
+
+func TestLabels(t *testing.T) {
+	project, err := Parse([]byte("services:\n  a:\n    labels:\n      - x=1\n      - y\n  b:\n    labels:\n      x: 1"))
+	assert.Nil(t, err)
+	assert.Equal(t, Labels{"x": "1", "y": ""}, project.Services["a"].Labels)
+	assert.Equal(t, Labels{"x": "1"}, project.Services["b"].Labels)
+
+	output, err := yaml.Marshal(project.Services["a"])
+	assert.Nil(t, err)
+	assert.Equal(t, "labels:\n    x: \"1\"\n    \"y\": \"\"\n", string(output))
+
+	_, err = Parse([]byte("services:\n  a:\n    labels: x"))
+	assert.NotNil(t, err)
+}
+
+func TestServiceNamesOfAddedServices(t *testing.T) {
+	project, err := Parse([]byte("services:\n  b:\n    image: b:1\n  a:\n    image: a:1"))
+	assert.Nil(t, err)
+	project.Services["d"] = &Service{}
+	project.Services["c"] = &Service{}
+	assert.Equal(t, []string{"b", "a", "c", "d"}, project.ServiceNames())
+}
+
+func TestPosition(t *testing.T) {
+	project, err := Parse([]byte(sampleCompose))
+	assert.Nil(t, err)
+	assert.Equal(t, Position{Line: 2, Column: 1}, project.Position("services"))
+	assert.Equal(t, Position{Line: 3, Column: 3}, project.Position("services", "gitea"))
+	assert.Equal(t, Position{Line: 4, Column: 5}, project.Position("services", "gitea", "image"))
+	assert.Equal(t, Position{Line: 8, Column: 9}, project.Position("services", "gitea", "ports", "1"))
+	assert.Equal(t, Position{Line: 5, Column: 5}, project.Position("services", "gitea", "container_name", "unknown"))
+	assert.Equal(t, Position{Line: 3, Column: 3}, project.Position("services", "gitea", "ports2"))
+	assert.Equal(t, Position{Line: 6, Column: 5}, project.Position("services", "gitea", "ports", "7"))
+	assert.Equal(t, Position{}, (&Project{}).Position("services"))
+}
@@ -0,0 +1,231 @@
+package compose
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"strings"
+)
+
+// Lookup returns the value of a variable and whether it is known.
+type Lookup func(name string) (string, bool)
+
+// variableReference is a single '$NAME' or '${NAME...}' in a value. The modifier and its argument are set for references
+// like '${NAME:-default}', where the modifier is ':-' and the argument is 'default'. The argument may reference
+// variables itself, like '${NAME:-${OTHER}}', which are listed in nested.
+type variableReference struct {
+	name     string
+	modifier string
+	argument string
+	nested   []variableReference
+	start    int
+	end      int
+}
+
+var variableModifiers = []string{":-", ":?", ":+", "-", "?", "+"}
+
+// ReferencedVariables returns the names of all variables referenced in the value in order of appearance. A literal
+// dollar sign is written as '$$' and is not a reference.
+func ReferencedVariables(value string) ([]string, error) {
+	references, err := parseVariableReferences(value)
+	if err != nil {
+		return nil, err
+	}
+	return referenceNames(references, make([]string, 0, len(references))), nil
+}
+
+func referenceNames(references []variableReference, names []string) []string {
+	for _, reference := range references {
+		names = append(names, reference.name)
+		names = referenceNames(reference.nested, names)
+	}
+	return names
+}
+
+// Substitute replaces the references of known variables in the value. References of unknown variables and escaped
+// dollar signs are kept, so that compose can still handle them, but known variables in their arguments are replaced.
+// Dollar signs in the substituted values are escaped, so that compose does not interpolate them again.
+func Substitute(value string, lookup Lookup) (string, error) {
+	references, err := parseVariableReferences(value)
+	if err != nil {
+		return "", err
+	}
+	var result strings.Builder
+	last := 0
+	for _, reference := range references {
+		argument, err := Substitute(reference.argument, lookup)
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(value[last:reference.start])
+		variableValue, known := lookup(reference.name)
+		switch {
+		case known:
+			result.WriteString(applyModifier(reference.modifier, escapeDollarSigns(variableValue), argument))
+		case reference.modifier != "":
+			result.WriteString("${" + reference.name + reference.modifier + argument + "}")
+		default:
+			result.WriteString(value[reference.start:reference.end])
+		}
+		last = reference.end
+	}
+	result.WriteString(value[last:])
+	return result.String(), nil
+}
+
+// applyModifier resolves the modifier of a reference to a known variable like compose would do. The value and the
+// substituted argument are both escaped already.
+func applyModifier(modifier, value, argument string) string {
+	switch modifier {
+	case ":-":
+		if value == "" {
+			return argument
+		}
+	case ":+":
+		if value != "" {
+			return argument
+		}
+		return ""
+	case "+":
+		return argument
+	}
+	return value
+}
+
+func escapeDollarSigns(value string) string {
+	return strings.ReplaceAll(value, "$", "$$")
+}
+
+func parseVariableReferences(value string) ([]variableReference, error) {
+	var references []variableReference
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' {
+			continue
+		}
+		if i+1 >= len(value) {
+			break
+		}
+		next := value[i+1]
+		switch {
+		case next == '$':
+			i++
+		case next == '{':
+			end := closingBrace(value[i:])
+			if end == -1 {
+				return nil, fmt.Errorf("missing closing brace in variable reference: %s", value[i:])
+			}
+			reference, err := parseBracedReference(value[i+2 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			reference.start, reference.end = i, i+end+1
+			references = append(references, reference)
+			i += end
+		case isVariableNameStart(next):
+			end := i + 1
+			for end < len(value) && isVariableNameChar(value[end]) {
+				end++
+			}
+			references = append(references, variableReference{name: value[i+1 : end], start: i, end: end})
+			i = end - 1
+		}
+	}
+	return references, nil
+}
+
+// closingBrace returns the index of the brace closing the reference at the start of the value, skipping the references
+// nested in its argument, or -1 if it is not closed.
+func closingBrace(value string) int {
+	depth := 0
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '$' && i+1 < len(value) && value[i+1] == '$':
+			i++
+		case value[i] == '$' && i+1 < len(value) && value[i+1] == '{':
+			depth++
+			i++
+		case value[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseBracedReference(content string) (variableReference, error) {
+	end := 0
+	for end < len(content) && isVariableNameChar(content[end]) {
+		end++
+	}
+	reference := variableReference{name: content[:end]}
+	if end == 0 || !isVariableNameStart(content[0]) {
+		return reference, fmt.Errorf("invalid variable name in reference: ${%s}", content)
+	}
+	rest := content[end:]
+	if rest == "" {
+		return reference, nil
+	}
+	for _, modifier := range variableModifiers {
+		if strings.HasPrefix(rest, modifier) {
+			reference.modifier = modifier
+			reference.argument = rest[len(modifier):]
+			nested, err := parseVariableReferences(reference.argument)
+			reference.nested = nested
+			return reference, err
+		}
+	}
+	return reference, fmt.Errorf("invalid variable reference: ${%s}", content)
+}
+
+func isVariableNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isVariableNameChar(c byte) bool {
+	return isVariableNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// MarshalSubstituted writes the project like Marshal, but substitutes the known variables in all values. Keys are
+// never substituted, like in compose.
+func (p *Project) MarshalSubstituted(lookup Lookup) ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(p); err != nil {
+		return nil, err
+	}
+	if err := substituteNode(&node, lookup); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(&node)
+}
+
+func substituteNode(node *yaml.Node, lookup Lookup) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+		value, err := Substitute(node.Value, lookup)
+		if err != nil {
+			return err
+		}
+		if value != node.Value {
+			node.Value = value
+			node.Tag = "!!str"
+			node.Style = 0
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := substituteNode(node.Content[i], lookup); err != nil {
+				return err
+			}
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := substituteNode(child, lookup); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package compose
+
+import (
+	"github.com/ocelot-cloud/shared/assert"
+	"strings"
+	"testing"
+)
+
+func lookupOf(variables map[string]string) Lookup {
+	return func(name string) (string, bool) {
+		value, ok := variables[name]
+		return value, ok
+	}
+}
+
+func TestReferencedVariables(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected []string
+	}{
+		{"no variables", []string{}},
+		{"${HOST}", []string{"HOST"}},
+		{"https://$HOST/path", []string{"HOST"}},
+		{"${A}${B_2}-$C", []string{"A", "B_2", "C"}},
+		{"$${HOST} and $$HOST", []string{}},
+		{"${A:-default} ${B-x} ${C:?error} ${D?e} ${E:+alt} ${F+alt}", []string{"A", "B", "C", "D", "E", "F"}},
+		{"price: 5$", []string{}},
+		{"$1", []string{}},
+		{"${HOST:-${FOO}}", []string{"HOST", "FOO"}},
+		{"${A:-${B:+${C}}} ${D}", []string{"A", "B", "C", "D"}},
+	}
+
+	for _, tc := range testCases {
+		names, err := ReferencedVariables(tc.value)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, names, tc.value)
+	}
+}
+
+func TestReferencedVariablesRejectsInvalidSyntax(t *testing.T) {
+	testCases := []struct {
+		value         string
+		expectedError string
+	}{
+		{"${HOST", "missing closing brace in variable reference: ${HOST"},
+		{"${}", "invalid variable name in reference: ${}"},
+		{"${1A}", "invalid variable name in reference: ${1A}"},
+		{"${HOST/a/b}", "invalid variable reference: ${HOST/a/b}"},
+		{"${HOST:-${FOO}", "missing closing brace in variable reference: ${HOST:-${FOO}"},
+		{"${HOST:-${1A}}", "invalid variable name in reference: ${1A}"},
+	}
+
+	for _, tc := range testCases {
+		_, err := ReferencedVariables(tc.value)
+		assert.NotNil(t, err, tc.value)
+		if err != nil {
+			assert.Equal(t, tc.expectedError, err.Error())
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	lookup := lookupOf(map[string]string{"HOST": "example.com", "EMPTY": "", "SECRET": "a$b"})
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		{"https://${HOST}/", "https://example.com/"},
+		{"https://$HOST/", "https://example.com/"},
+		{"$${HOST} costs 5$$", "$${HOST} costs 5$$"},
+		{"${UNKNOWN} $UNKNOWN", "${UNKNOWN} $UNKNOWN"},
+		{"${EMPTY:-fallback} ${HOST:-fallback}", "fallback example.com"},
+		{"${EMPTY:+set} ${HOST:+set} ${EMPTY+set}", " set set"},
+		{"password=${SECRET}", "password=a$$b"},
+		{"${HOST:-${FOO}}", "example.com"},
+		{"${EMPTY:-${HOST}}/", "example.com/"},
+		{"${EMPTY:-${SECRET}} ${EMPTY:-a$$b}", "a$$b a$$b"},
+		{"${UNKNOWN:-${HOST}} ${UNKNOWN:-${FOO}}", "${UNKNOWN:-example.com} ${UNKNOWN:-${FOO}}"},
+	}
+
+	for _, tc := range testCases {
+		actual, err := Substitute(tc.value, lookup)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, actual)
+	}
+}
+
+func TestMarshalSubstituted(t *testing.T) {
+	project, err := Parse([]byte("services:\n  app:\n    image: app:1.0\n    environment:\n      ${HOST}: ${HOST}\n      PORT: ${PORT}\n    command: echo $$HOST"))
+	assert.Nil(t, err)
+	output, err := project.MarshalSubstituted(lookupOf(map[string]string{"HOST": "example.com", "PORT": "8080"}))
+	assert.Nil(t, err)
+	content := string(output)
+	assert.True(t, strings.Contains(content, "${HOST}: example.com"), content)
+	assert.True(t, strings.Contains(content, `PORT: "8080"`), content)
+	assert.True(t, strings.Contains(content, "command: echo $$HOST"), content)
+
+	project, err = Parse([]byte("services:\n  app:\n    image: ${HOST"))
+	assert.Nil(t, err)
+	_, err = project.MarshalSubstituted(lookupOf(nil))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,193 @@
+package compose
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PortRange is a range of ports like "8000-8010". A single port has the same start and end. A zero range means that
+// no port is given, like the published port in "127.0.0.1::80".
+type PortRange struct {
+	Start int
+	End   int
+}
+
+func (r PortRange) IsZero() bool {
+	return r.Start == 0 && r.End == 0
+}
+
+func (r PortRange) Contains(port int) bool {
+	return !r.IsZero() && r.Start <= port && port <= r.End
+}
+
+func (r PortRange) size() int {
+	return r.End - r.Start + 1
+}
+
+func (r PortRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// PortMapping is the normalized form of a port of a service, regardless of whether it was given in short or long syntax.
+type PortMapping struct {
+	HostIP    string
+	Published PortRange
+	Target    PortRange
+	Protocol  string
+}
+
+// Mapping normalizes the port. The short syntax "[host_ip:][published:]target[/protocol]" with port ranges is
+// supported, like "127.0.0.1:8000-8001:80-81/udp". The protocol defaults to "tcp".
+func (p Port) Mapping() (PortMapping, error) {
+	if p.IsLongSyntax() {
+		return p.longSyntaxMapping()
+	}
+	mapping := PortMapping{Protocol: "tcp"}
+	value := p.Short
+	if index := strings.LastIndex(value, "/"); index != -1 {
+		value, mapping.Protocol = value[:index], value[index+1:]
+	}
+	if strings.HasPrefix(value, "[") {
+		end := strings.Index(value, "]:")
+		if end == -1 {
+			return mapping, fmt.Errorf("invalid port: %s", p.Short)
+		}
+		mapping.HostIP, value = value[1:end], value[end+2:]
+	}
+	fields := strings.Split(value, ":")
+	if len(fields) == 3 && mapping.HostIP == "" {
+		mapping.HostIP, fields = fields[0], fields[1:]
+	}
+	var err error
+	switch len(fields) {
+	case 1:
+		mapping.Target, err = parsePortRange(fields[0])
+	case 2:
+		if fields[0] != "" {
+			if mapping.Published, err = parsePortRange(fields[0]); err != nil {
+				break
+			}
+		}
+		mapping.Target, err = parsePortRange(fields[1])
+	default:
+		err = fmt.Errorf("too many colons")
+	}
+	if err != nil {
+		return mapping, fmt.Errorf("invalid port %s: %v", p.Short, err)
+	}
+	return mapping, mapping.validate(p.Short)
+}
+
+func (p Port) longSyntaxMapping() (PortMapping, error) {
+	mapping := PortMapping{HostIP: p.HostIP, Target: PortRange{p.Target, p.Target}, Protocol: p.Protocol}
+	if mapping.Protocol == "" {
+		mapping.Protocol = "tcp"
+	}
+	description := fmt.Sprintf("with target %d", p.Target)
+	if p.Target < 1 || p.Target > 65535 {
+		return mapping, fmt.Errorf("invalid port %s: port must be between 1 and 65535", description)
+	}
+	if p.Published != "" {
+		published, err := parsePortRange(p.Published)
+		if err != nil {
+			return mapping, fmt.Errorf("invalid port %s: %v", description, err)
+		}
+		mapping.Published = published
+	}
+	return mapping, mapping.validate(description)
+}
+
+func (m PortMapping) validate(description string) error {
+	if m.Protocol != "tcp" && m.Protocol != "udp" && m.Protocol != "sctp" {
+		return fmt.Errorf("invalid port %s: unknown protocol '%s'", description, m.Protocol)
+	}
+	if !m.Published.IsZero() && m.Target.size() > 1 && m.Published.size() != m.Target.size() {
+		return fmt.Errorf("invalid port %s: the published and the target range must have the same size", description)
+	}
+	return nil
+}
+
+func parsePortRange(value string) (PortRange, error) {
+	start, end, isRange := strings.Cut(value, "-")
+	if !isRange {
+		end = start
+	}
+	var r PortRange
+	var err error
+	if r.Start, err = parsePortNumber(start); err != nil {
+		return r, err
+	}
+	if r.End, err = parsePortNumber(end); err != nil {
+		return r, err
+	}
+	if r.Start > r.End {
+		return r, fmt.Errorf("port range %s must not end before it starts", value)
+	}
+	return r, nil
+}
+
+func parsePortNumber(value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port must be between 1 and 65535, but got '%s'", value)
+	}
+	return port, nil
+}
+
+// Mount types of a service volume.
+const (
+	MountTypeVolume = "volume"
+	MountTypeBind   = "bind"
+	MountTypeTmpfs  = "tmpfs"
+)
+
+// Mount is the normalized form of a volume of a service, regardless of whether it was given in short or long syntax.
+// Source is empty for anonymous volumes and tmpfs mounts.
+type Mount struct {
+	Type     string
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// Mount normalizes the volume. In short syntax "[source:]target[:mode]", a source starting with '/', '.' or '~' is a
+// host directory, any other source is the name of a volume.
+func (v ServiceVolume) Mount() (Mount, error) {
+	if v.IsLongSyntax() {
+		if v.Type == "" || v.Target == "" {
+			return Mount{}, fmt.Errorf("invalid volume: 'type' and 'target' are required")
+		}
+		return Mount{Type: v.Type, Source: v.Source, Target: v.Target, ReadOnly: v.ReadOnly}, nil
+	}
+	fields := strings.Split(v.Short, ":")
+	if len(fields) > 3 {
+		return Mount{}, fmt.Errorf("invalid volume: %s", v.Short)
+	}
+	if len(fields) == 1 {
+		return Mount{Type: MountTypeVolume, Target: fields[0]}, nil
+	}
+	mount := Mount{Type: MountTypeVolume, Source: fields[0], Target: fields[1]}
+	if isHostPath(mount.Source) {
+		mount.Type = MountTypeBind
+	}
+	if len(fields) == 3 {
+		for _, option := range strings.Split(fields[2], ",") {
+			if option == "ro" {
+				mount.ReadOnly = true
+			}
+		}
+	}
+	if mount.Source == "" || mount.Target == "" {
+		return mount, fmt.Errorf("invalid volume: %s", v.Short)
+	}
+	return mount, nil
+}
+
+func isHostPath(source string) bool {
+	return filepath.IsAbs(source) || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~")
+}
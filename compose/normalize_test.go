@@ -0,0 +1,89 @@
+package compose
+
+import (
+	"github.com/ocelot-cloud/shared/assert"
+	"testing"
+)
+
+func TestPortMapping(t *testing.T) {
+	testCases := []struct {
+		port     Port
+		expected PortMapping
+	}{
+		{Port{Short: "80"}, PortMapping{Target: PortRange{80, 80}, Protocol: "tcp"}},
+		{Port{Short: "8080:80"}, PortMapping{Published: PortRange{8080, 8080}, Target: PortRange{80, 80}, Protocol: "tcp"}},
+		{Port{Short: "0.0.0.0:80:3000"}, PortMapping{HostIP: "0.0.0.0", Published: PortRange{80, 80}, Target: PortRange{3000, 3000}, Protocol: "tcp"}},
+		{Port{Short: "127.0.0.1::80/udp"}, PortMapping{HostIP: "127.0.0.1", Target: PortRange{80, 80}, Protocol: "udp"}},
+		{Port{Short: "[::1]:8000-8001:80-81"}, PortMapping{HostIP: "::1", Published: PortRange{8000, 8001}, Target: PortRange{80, 81}, Protocol: "tcp"}},
+		{Port{Short: "9000-9010:80"}, PortMapping{Published: PortRange{9000, 9010}, Target: PortRange{80, 80}, Protocol: "tcp"}},
+		{Port{Target: 53, Published: "53", Protocol: "udp"}, PortMapping{Published: PortRange{53, 53}, Target: PortRange{53, 53}, Protocol: "udp"}},
+		{Port{Target: 3000, HostIP: "127.0.0.1"}, PortMapping{HostIP: "127.0.0.1", Target: PortRange{3000, 3000}, Protocol: "tcp"}},
+	}
+
+	for _, tc := range testCases {
+		mapping, err := tc.port.Mapping()
+		assert.Nil(t, err, tc.port.Short)
+		assert.Equal(t, tc.expected, mapping)
+	}
+}
+
+func TestInvalidPortMapping(t *testing.T) {
+	testCases := []struct {
+		port          Port
+		expectedError string
+	}{
+		{Port{Short: "http"}, "invalid port http: port must be between 1 and 65535, but got 'http'"},
+		{Port{Short: "70000:80"}, "invalid port 70000:80: port must be between 1 and 65535, but got '70000'"},
+		{Port{Short: "81-80"}, "invalid port 81-80: port range 81-80 must not end before it starts"},
+		{Port{Short: "1:2:3:4"}, "invalid port 1:2:3:4: too many colons"},
+		{Port{Short: "8000-8002:80-81"}, "invalid port 8000-8002:80-81: the published and the target range must have the same size"},
+		{Port{Short: "80/http"}, "invalid port 80/http: unknown protocol 'http'"},
+		{Port{Short: "[::1:80:80"}, "invalid port: [::1:80:80"},
+		{Port{Published: "80"}, "invalid port with target 0: port must be between 1 and 65535"},
+	}
+
+	for _, tc := range testCases {
+		_, err := tc.port.Mapping()
+		assert.NotNil(t, err, tc.expectedError)
+		if err != nil {
+			assert.Equal(t, tc.expectedError, err.Error())
+		}
+	}
+}
+
+func TestPortRangeContains(t *testing.T) {
+	assert.True(t, PortRange{440, 450}.Contains(443))
+	assert.False(t, PortRange{440, 450}.Contains(80))
+	assert.False(t, PortRange{}.Contains(0))
+	assert.Equal(t, "440-450", PortRange{440, 450}.String())
+	assert.Equal(t, "80", PortRange{80, 80}.String())
+}
+
+func TestServiceVolumeMount(t *testing.T) {
+	testCases := []struct {
+		volume   ServiceVolume
+		expected Mount
+	}{
+		{ServiceVolume{Short: "data:/data"}, Mount{Type: MountTypeVolume, Source: "data", Target: "/data"}},
+		{ServiceVolume{Short: "data:/data:ro,z"}, Mount{Type: MountTypeVolume, Source: "data", Target: "/data", ReadOnly: true}},
+		{ServiceVolume{Short: "/data"}, Mount{Type: MountTypeVolume, Target: "/data"}},
+		{ServiceVolume{Short: "./data:/data"}, Mount{Type: MountTypeBind, Source: "./data", Target: "/data"}},
+		{ServiceVolume{Short: "~/data:/data"}, Mount{Type: MountTypeBind, Source: "~/data", Target: "/data"}},
+		{ServiceVolume{Short: "/etc/timezone:/etc/timezone:ro"}, Mount{Type: MountTypeBind, Source: "/etc/timezone", Target: "/etc/timezone", ReadOnly: true}},
+		{ServiceVolume{Type: "volume", Source: "data", Target: "/data", ReadOnly: true}, Mount{Type: MountTypeVolume, Source: "data", Target: "/data", ReadOnly: true}},
+		{ServiceVolume{Type: "tmpfs", Target: "/cache"}, Mount{Type: MountTypeTmpfs, Target: "/cache"}},
+	}
+
+	for _, tc := range testCases {
+		mount, err := tc.volume.Mount()
+		assert.Nil(t, err, tc.volume.Short)
+		assert.Equal(t, tc.expected, mount)
+	}
+
+	_, err := ServiceVolume{Short: "a:b:c:d"}.Mount()
+	assert.NotNil(t, err)
+	_, err = ServiceVolume{Short: ":/data"}.Mount()
+	assert.NotNil(t, err)
+	_, err = ServiceVolume{Source: "data", Target: "/data"}.Mount()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,353 @@
+package compose
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strings"
+)
+
+// Port is a port mapping of a service. Either Short is set for the short syntax, like "8080:80", or the remaining
+// fields are set for the long syntax.
+type Port struct {
+	Short string `yaml:"-"`
+
+	Target      int    `yaml:"target,omitempty"`
+	Published   string `yaml:"published,omitempty"`
+	HostIP      string `yaml:"host_ip,omitempty"`
+	Protocol    string `yaml:"protocol,omitempty"`
+	Mode        string `yaml:"mode,omitempty"`
+	Name        string `yaml:"name,omitempty"`
+	AppProtocol string `yaml:"app_protocol,omitempty"`
+}
+
+func (p *Port) IsLongSyntax() bool {
+	return p.Short == ""
+}
+
+func (p *Port) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value == "" {
+			return fmt.Errorf("line %d: port must not be empty", node.Line)
+		}
+		*p = Port{Short: node.Value}
+		return nil
+	case yaml.MappingNode:
+		type plain Port
+		var long plain
+		if err := node.Decode(&long); err != nil {
+			return err
+		}
+		*p = Port(long)
+		p.Short = ""
+		return nil
+	default:
+		return fmt.Errorf("line %d: port must be a string or a map", node.Line)
+	}
+}
+
+func (p Port) MarshalYAML() (interface{}, error) {
+	if p.Short != "" {
+		return p.Short, nil
+	}
+	type plain Port
+	return plain(p), nil
+}
+
+// ServiceVolume is a volume mounted into a service. Either Short is set for the short syntax, like "data:/data:ro",
+// or the remaining fields are set for the long syntax.
+type ServiceVolume struct {
+	Short string `yaml:"-"`
+
+	Type        string                 `yaml:"type,omitempty"`
+	Source      string                 `yaml:"source,omitempty"`
+	Target      string                 `yaml:"target,omitempty"`
+	ReadOnly    bool                   `yaml:"read_only,omitempty"`
+	Consistency string                 `yaml:"consistency,omitempty"`
+	Bind        *BindOptions           `yaml:"bind,omitempty"`
+	Volume      *VolumeOptions         `yaml:"volume,omitempty"`
+	Tmpfs       *TmpfsOptions          `yaml:"tmpfs,omitempty"`
+	Extra       map[string]interface{} `yaml:",inline"`
+}
+
+type BindOptions struct {
+	Propagation    string `yaml:"propagation,omitempty"`
+	CreateHostPath bool   `yaml:"create_host_path,omitempty"`
+	SELinux        string `yaml:"selinux,omitempty"`
+}
+
+type VolumeOptions struct {
+	NoCopy  bool   `yaml:"nocopy,omitempty"`
+	Subpath string `yaml:"subpath,omitempty"`
+}
+
+type TmpfsOptions struct {
+	Size string `yaml:"size,omitempty"`
+	Mode int    `yaml:"mode,omitempty"`
+}
+
+func (v *ServiceVolume) IsLongSyntax() bool {
+	return v.Short == ""
+}
+
+func (v *ServiceVolume) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value == "" {
+			return fmt.Errorf("line %d: volume must not be empty", node.Line)
+		}
+		*v = ServiceVolume{Short: node.Value}
+		return nil
+	case yaml.MappingNode:
+		type plain ServiceVolume
+		var long plain
+		if err := node.Decode(&long); err != nil {
+			return err
+		}
+		*v = ServiceVolume(long)
+		v.Short = ""
+		return nil
+	default:
+		return fmt.Errorf("line %d: volume must be a string or a map", node.Line)
+	}
+}
+
+func (v ServiceVolume) MarshalYAML() (interface{}, error) {
+	if v.Short != "" {
+		return v.Short, nil
+	}
+	type plain ServiceVolume
+	return plain(v), nil
+}
+
+// EnvironmentVariable is an entry of the 'environment' section. Value is nil if the variable is declared without a value.
+type EnvironmentVariable struct {
+	Name  string
+	Value *string
+}
+
+// Environment holds the environment variables of a service in declaration order. They can be given as
+// list, like "KEY=value", or as map. The syntax is kept when writing the file again.
+type Environment struct {
+	Variables []EnvironmentVariable
+	MapSyntax bool
+}
+
+func (e Environment) IsZero() bool {
+	return len(e.Variables) == 0
+}
+
+// Get returns the value of the variable with the given name.
+func (e Environment) Get(name string) (string, bool) {
+	for _, variable := range e.Variables {
+		if variable.Name == name && variable.Value != nil {
+			return *variable.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set overrides the value of the variable or appends it, if it is not declared yet.
+func (e *Environment) Set(name, value string) {
+	for i := range e.Variables {
+		if e.Variables[i].Name == name {
+			e.Variables[i].Value = &value
+			return
+		}
+	}
+	e.Variables = append(e.Variables, EnvironmentVariable{Name: name, Value: &value})
+}
+
+func (e *Environment) UnmarshalYAML(node *yaml.Node) error {
+	*e = Environment{}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: environment list entries must be strings", item.Line)
+			}
+			name, value, hasValue := strings.Cut(item.Value, "=")
+			variable := EnvironmentVariable{Name: name}
+			if hasValue {
+				variable.Value = &value
+			}
+			e.Variables = append(e.Variables, variable)
+		}
+	case yaml.MappingNode:
+		e.MapSyntax = true
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			valueNode := node.Content[i+1]
+			if valueNode.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: environment values must be strings, numbers or booleans", valueNode.Line)
+			}
+			variable := EnvironmentVariable{Name: node.Content[i].Value}
+			if !isNull(valueNode) {
+				value := valueNode.Value
+				variable.Value = &value
+			}
+			e.Variables = append(e.Variables, variable)
+		}
+	default:
+		return fmt.Errorf("line %d: environment must be a list or a map", node.Line)
+	}
+	return nil
+}
+
+func (e Environment) MarshalYAML() (interface{}, error) {
+	if e.MapSyntax {
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, variable := range e.Variables {
+			valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: ""}
+			if variable.Value != nil {
+				valueNode = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: *variable.Value}
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: variable.Name}, valueNode)
+		}
+		return node, nil
+	}
+	list := make([]string, 0, len(e.Variables))
+	for _, variable := range e.Variables {
+		if variable.Value == nil {
+			list = append(list, variable.Name)
+		} else {
+			list = append(list, variable.Name+"="+*variable.Value)
+		}
+	}
+	return list, nil
+}
+
+// StringOrList is a value like 'command' which can be given as a single string or as a list of strings.
+// The syntax is kept when writing the file again, since compose treats both forms differently.
+type StringOrList struct {
+	Values []string
+	IsList bool
+}
+
+func (s StringOrList) IsZero() bool {
+	return len(s.Values) == 0 && !s.IsList
+}
+
+func (s *StringOrList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*s = StringOrList{Values: []string{node.Value}}
+		return nil
+	case yaml.SequenceNode:
+		var values []string
+		if err := node.Decode(&values); err != nil {
+			return err
+		}
+		*s = StringOrList{Values: values, IsList: true}
+		return nil
+	default:
+		return fmt.Errorf("line %d: value must be a string or a list of strings", node.Line)
+	}
+}
+
+func (s StringOrList) MarshalYAML() (interface{}, error) {
+	if !s.IsList && len(s.Values) == 1 {
+		return s.Values[0], nil
+	}
+	return s.Values, nil
+}
+
+// Dependency holds the long syntax options of a 'depends_on' entry.
+type Dependency struct {
+	Condition string `yaml:"condition,omitempty"`
+	Restart   bool   `yaml:"restart,omitempty"`
+	Required  *bool  `yaml:"required,omitempty"`
+}
+
+// DependsOn lists the services a service depends on. Conditions is only set if the long syntax was used.
+type DependsOn struct {
+	Services   []string
+	Conditions map[string]Dependency
+}
+
+func (d DependsOn) IsZero() bool {
+	return len(d.Services) == 0
+}
+
+func (d *DependsOn) UnmarshalYAML(node *yaml.Node) error {
+	*d = DependsOn{}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		return node.Decode(&d.Services)
+	case yaml.MappingNode:
+		if err := node.Decode(&d.Conditions); err != nil {
+			return err
+		}
+		d.Services = mappingKeys(node)
+		return nil
+	default:
+		return fmt.Errorf("line %d: 'depends_on' must be a list or a map", node.Line)
+	}
+}
+
+func (d DependsOn) MarshalYAML() (interface{}, error) {
+	if d.Conditions != nil {
+		return d.Conditions, nil
+	}
+	return d.Services, nil
+}
+
+// NetworkList holds the names of the networks a service is attached to, given either as list or as map.
+// It is always written as list.
+type NetworkList []string
+
+func (n *NetworkList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		var names []string
+		if err := node.Decode(&names); err != nil {
+			return err
+		}
+		*n = names
+		return nil
+	case yaml.MappingNode:
+		*n = mappingKeys(node)
+		return nil
+	default:
+		return fmt.Errorf("line %d: 'networks' must be a list or a map", node.Line)
+	}
+}
+
+// Labels holds the labels of a service, given either as list of "key=value" entries or as map. They are always
+// written as map.
+type Labels map[string]string
+
+func (l *Labels) UnmarshalYAML(node *yaml.Node) error {
+	labels := Labels{}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: label list entries must be strings", item.Line)
+			}
+			key, value, _ := strings.Cut(item.Value, "=")
+			labels[key] = value
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			valueNode := node.Content[i+1]
+			if valueNode.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: label values must be strings, numbers or booleans", valueNode.Line)
+			}
+			labels[node.Content[i].Value] = valueNode.Value
+		}
+	default:
+		return fmt.Errorf("line %d: 'labels' must be a list or a map", node.Line)
+	}
+	*l = labels
+	return nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
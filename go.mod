@@ -4,9 +4,11 @@ go 1.24.4
 
 require (
 	github.com/golang-migrate/migrate/v4 v4.18.3
+	github.com/klauspost/compress v1.18.0
 	github.com/lib/pq v1.10.9
 	github.com/ocelot-cloud/deepstack v0.0.2
 	github.com/ocelot-cloud/task-runner v0.0.28
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/crypto v0.40.0
 	gopkg.in/yaml.v3 v3.0.1
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"crypto/ed25519"
 	"github.com/ocelot-cloud/shared/utils"
 	"time"
 )
@@ -31,6 +32,8 @@ type FullVersionInfo struct {
 	AppName                  string    `json:"app_name"`
 	Content                  []byte    `json:"content"`
 	VersionCreationTimestamp time.Time `json:"version_creation_timestamp"`
+	// Signature is nil for versions uploaded before signing was introduced.
+	Signature *VersionSignature `json:"signature,omitempty"`
 }
 
 type RegistrationForm struct {
@@ -41,6 +44,11 @@ type RegistrationForm struct {
 
 type AppStoreClient struct {
 	Parent utils.ComponentClient
+	// SigningKey signs uploaded versions if set.
+	SigningKey ed25519.PrivateKey
+	// RequireSignedVersions makes DownloadVersion reject versions without signature. It is off by default, since the
+	// versions uploaded before signing was introduced are unsigned. Signed versions are verified anyway.
+	RequireSignedVersions bool
 }
 
 type LoginCredentials struct {
@@ -72,7 +80,21 @@ type App struct {
 }
 
 type VersionUpload struct {
-	AppId   string `json:"appId" validate:"number"`
-	Version string `json:"version" validate:"version_name"`
-	Content []byte `json:"content"`
+	AppId     string            `json:"appId" validate:"number"`
+	Version   string            `json:"version" validate:"version_name"`
+	Content   []byte            `json:"content"`
+	Signature *VersionSignature `json:"signature,omitempty"`
+}
+
+type PublicKeyUpload struct {
+	PublicKey []byte `json:"public_key"`
+}
+
+type KeyRevocation struct {
+	KeyId     string    `json:"key_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type MaintainerString struct {
+	Value string `json:"value" validate:"user_name"`
 }
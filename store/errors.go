@@ -0,0 +1,51 @@
+package store
+
+import (
+	"errors"
+	"github.com/ocelot-cloud/shared/utils"
+	"net/http"
+)
+
+// Domain errors of the store. Errors returned by AppStoreClient wrap them together with the utils.HTTPError of the
+// response, so that callers can use errors.Is instead of matching messages. The messages stay unchanged.
+var (
+	ErrInvalidInput  = errors.New("invalid input")
+	ErrUnauthorized  = errors.New("not logged in")
+	ErrForbidden     = errors.New("operation not allowed")
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+)
+
+var statusCodeErrors = map[int]error{
+	http.StatusBadRequest:   ErrInvalidInput,
+	http.StatusUnauthorized: ErrUnauthorized,
+	http.StatusForbidden:    ErrForbidden,
+	http.StatusNotFound:     ErrNotFound,
+	http.StatusConflict:     ErrAlreadyExists,
+}
+
+// domainError adds a domain error to an error without changing its message.
+type domainError struct {
+	domain error
+	err    error
+}
+
+func (e *domainError) Error() string {
+	return e.err.Error()
+}
+
+func (e *domainError) Unwrap() []error {
+	return []error{e.domain, e.err}
+}
+
+// toDomainError wraps errors of store responses with the domain error of their status code.
+func toDomainError(err error) error {
+	var httpError *utils.HTTPError
+	if !errors.As(err, &httpError) {
+		return err
+	}
+	if domain, ok := statusCodeErrors[httpError.StatusCode]; ok {
+		return &domainError{domain, err}
+	}
+	return err
+}
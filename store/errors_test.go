@@ -0,0 +1,51 @@
+package store
+
+import (
+	"errors"
+	"github.com/ocelot-cloud/shared/assert"
+	"github.com/ocelot-cloud/shared/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStoreResponsesAreMappedOntoDomainErrors(t *testing.T) {
+	testCases := []struct {
+		statusCode int
+		expected   error
+	}{
+		{http.StatusBadRequest, ErrInvalidInput},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrAlreadyExists},
+	}
+
+	for _, tc := range testCases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "failed", tc.statusCode)
+		}))
+		client := &AppStoreClient{Parent: utils.ComponentClient{RootUrl: server.URL}}
+		_, err := client.ListOwnApps()
+		server.Close()
+
+		assert.True(t, errors.Is(err, tc.expected), http.StatusText(tc.statusCode))
+		assert.True(t, utils.HasStatusCode(err, tc.statusCode))
+		if err != nil {
+			assert.Equal(t, utils.GetErrMsg(tc.statusCode, "failed"), err.Error())
+		}
+	}
+}
+
+func TestMissingAppIsNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.SendJsonResponse(w, []App{})
+	}))
+	defer server.Close()
+
+	_, err := (&AppStoreClient{Parent: utils.ComponentClient{RootUrl: server.URL}}).CreateApp("gitea")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	if err != nil {
+		assert.Equal(t, "app not found on server", err.Error())
+	}
+}
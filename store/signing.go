@@ -0,0 +1,137 @@
+package store
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Versions are signed by their maintainers with ed25519 keys. The signature covers the SHA-256 digest of the version
+// archive, which is reproducible (see utils.PackDirectory), so the digest also identifies the content. It also covers
+// the maintainer, app and version name and the time of signing, so that the store can neither serve an archive as
+// another version nor change when it was created.
+//
+// Keys are rotated by publishing a new key, signing new versions with it and then revoking the old key. Versions
+// which were signed before the revocation keep verifying with the old key, later versions signed by it are rejected.
+// A compromised key should be revoked with the time it was compromised.
+
+const (
+	digestPrefix = "sha256:"
+	// signaturePrefix separates version signatures from other data signed by the same key.
+	signaturePrefix = "ocelot-cloud version\n"
+)
+
+// VersionMetadata identifies the version a signature was made for.
+type VersionMetadata struct {
+	Maintainer string    `json:"maintainer"`
+	App        string    `json:"app"`
+	Version    string    `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// VersionSignature is the detached signature of a version archive.
+type VersionSignature struct {
+	// Digest is the SHA-256 digest of the archive in the form "sha256:<hex>".
+	Digest string `json:"digest"`
+	VersionMetadata
+	KeyId     string `json:"key_id"`
+	Signature []byte `json:"signature"`
+}
+
+// signedPayload is the canonical form of the signed data. The fields are encoded as JSON in this order.
+type signedPayload struct {
+	Digest     string `json:"digest"`
+	Maintainer string `json:"maintainer"`
+	App        string `json:"app"`
+	Version    string `json:"version"`
+	CreatedAt  string `json:"created_at"`
+}
+
+func (s VersionSignature) payload() []byte {
+	payload, err := json.Marshal(signedPayload{
+		Digest:     s.Digest,
+		Maintainer: s.Maintainer,
+		App:        s.App,
+		Version:    s.Version,
+		CreatedAt:  s.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode signed payload: %v", err))
+	}
+	return append([]byte(signaturePrefix), payload...)
+}
+
+// MaintainerKey is a public key a maintainer published to the store.
+type MaintainerKey struct {
+	KeyId     string    `json:"key_id"`
+	PublicKey []byte    `json:"public_key"`
+	CreatedAt time.Time `json:"created_at"`
+	// RevokedAt is set when the key was revoked. Versions signed afterward are not accepted with this key.
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Digest returns the SHA-256 digest of the content in the form "sha256:<hex>".
+func Digest(content []byte) string {
+	hash := sha256.Sum256(content)
+	return digestPrefix + hex.EncodeToString(hash[:])
+}
+
+// KeyId identifies a public key by the first 16 bytes of its SHA-256 hash in hex.
+func KeyId(publicKey ed25519.PublicKey) string {
+	hash := sha256.Sum256(publicKey)
+	return hex.EncodeToString(hash[:16])
+}
+
+// SignVersion creates the detached signature of the version archive with the metadata of the version.
+func SignVersion(content []byte, metadata VersionMetadata, privateKey ed25519.PrivateKey) VersionSignature {
+	signature := VersionSignature{
+		Digest:          Digest(content),
+		VersionMetadata: metadata,
+		KeyId:           KeyId(privateKey.Public().(ed25519.PublicKey)),
+	}
+	signature.Signature = ed25519.Sign(privateKey, signature.payload())
+	return signature
+}
+
+// VerifyVersion checks that the signature was made for the content and the version by one of the keys. The key must
+// not have been revoked before the version was created, which is the later of the signed time and the creation time
+// of the version, since the store reports the latter. Backdating a version therefore needs both the key and the store.
+func VerifyVersion(content []byte, signature VersionSignature, keys []MaintainerKey, version VersionMetadata) error {
+	if signature.Digest != Digest(content) {
+		return fmt.Errorf("digest of version does not match, expected %s but got %s", signature.Digest, Digest(content))
+	}
+	if signature.Maintainer != version.Maintainer || signature.App != version.App || signature.Version != version.Version {
+		return fmt.Errorf("signature was made for version %s of app %s/%s, not for version %s of app %s/%s",
+			signature.Version, signature.Maintainer, signature.App, version.Version, version.Maintainer, version.App)
+	}
+	key, err := findKey(keys, signature.KeyId)
+	if err != nil {
+		return err
+	}
+	if len(key.PublicKey) != ed25519.PublicKeySize || KeyId(key.PublicKey) != key.KeyId {
+		return fmt.Errorf("invalid public key %s", key.KeyId)
+	}
+	if !ed25519.Verify(key.PublicKey, signature.payload(), signature.Signature) {
+		return fmt.Errorf("invalid signature of version with key %s", key.KeyId)
+	}
+	createdAt := signature.CreatedAt
+	if version.CreatedAt.After(createdAt) {
+		createdAt = version.CreatedAt
+	}
+	if key.RevokedAt != nil && !createdAt.Before(*key.RevokedAt) {
+		return fmt.Errorf("key %s was revoked at %s, before the version was created", key.KeyId, key.RevokedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func findKey(keys []MaintainerKey, keyId string) (*MaintainerKey, error) {
+	for i := range keys {
+		if keys[i].KeyId == keyId {
+			return &keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("key %s is not a published key of the maintainer", keyId)
+}
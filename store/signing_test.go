@@ -0,0 +1,159 @@
+package store
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"github.com/ocelot-cloud/shared/assert"
+	"github.com/ocelot-cloud/shared/utils"
+	"github.com/ocelot-cloud/shared/validation"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testSigningKey derives a deterministic key from the name, so that tests can sign versions without generating or
+// storing keys.
+func testSigningKey(name string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte("ocelot-cloud test key " + name))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func publishedKey(privateKey ed25519.PrivateKey, revokedAt *time.Time) MaintainerKey {
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	return MaintainerKey{KeyId: KeyId(publicKey), PublicKey: publicKey, RevokedAt: revokedAt}
+}
+
+func TestVerifyVersion(t *testing.T) {
+	content := []byte("version archive")
+	oldKey := testSigningKey("old")
+	newKey := testSigningKey("new")
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	revokedBefore := createdAt.Add(-time.Hour)
+	revokedAfter := createdAt.Add(time.Hour)
+	version := VersionMetadata{Maintainer: "samplemaintainer", App: "gitea", Version: "1.0", CreatedAt: createdAt}
+	backdated := version
+	backdated.CreatedAt = createdAt.Add(-2 * time.Hour)
+	otherApp := version
+	otherApp.App = "other"
+
+	forgedSignature := SignVersion(content, version, newKey)
+	forgedSignature.KeyId = KeyId(oldKey.Public().(ed25519.PublicKey))
+	tamperedSignature := SignVersion(content, version, newKey)
+	tamperedSignature.CreatedAt = backdated.CreatedAt
+
+	testCases := []struct {
+		name          string
+		content       []byte
+		signature     VersionSignature
+		keys          []MaintainerKey
+		version       VersionMetadata
+		expectedError string
+	}{
+		{"valid", content, SignVersion(content, version, newKey), []MaintainerKey{publishedKey(oldKey, nil), publishedKey(newKey, nil)}, version, ""},
+		{"signed before revocation", content, SignVersion(content, version, oldKey), []MaintainerKey{publishedKey(oldKey, &revokedAfter)}, version, ""},
+		{"signed after revocation", content, SignVersion(content, version, oldKey), []MaintainerKey{publishedKey(oldKey, &revokedBefore)}, version, "key " + KeyId(oldKey.Public().(ed25519.PublicKey)) + " was revoked at 2024-12-31T23:00:00Z, before the version was created"},
+		{"backdated signature", content, SignVersion(content, backdated, oldKey), []MaintainerKey{publishedKey(oldKey, &revokedBefore)}, version, "key " + KeyId(oldKey.Public().(ed25519.PublicKey)) + " was revoked at 2024-12-31T23:00:00Z, before the version was created"},
+		{"backdated by the store", content, SignVersion(content, version, oldKey), []MaintainerKey{publishedKey(oldKey, &revokedBefore)}, backdated, "key " + KeyId(oldKey.Public().(ed25519.PublicKey)) + " was revoked at 2024-12-31T23:00:00Z, before the version was created"},
+		{"other app", content, SignVersion(content, version, newKey), []MaintainerKey{publishedKey(newKey, nil)}, otherApp, "signature was made for version 1.0 of app samplemaintainer/gitea, not for version 1.0 of app samplemaintainer/other"},
+		{"tampered content", []byte("tampered"), SignVersion(content, version, newKey), []MaintainerKey{publishedKey(newKey, nil)}, version, "digest of version does not match, expected " + Digest(content) + " but got " + Digest([]byte("tampered"))},
+		{"tampered creation time", content, tamperedSignature, []MaintainerKey{publishedKey(newKey, nil)}, version, "invalid signature of version with key " + tamperedSignature.KeyId},
+		{"unknown key", content, SignVersion(content, version, newKey), []MaintainerKey{publishedKey(oldKey, nil)}, version, "key " + KeyId(newKey.Public().(ed25519.PublicKey)) + " is not a published key of the maintainer"},
+		{"forged signature", content, forgedSignature, []MaintainerKey{publishedKey(oldKey, nil)}, version, "invalid signature of version with key " + forgedSignature.KeyId},
+	}
+
+	for _, tc := range testCases {
+		err := VerifyVersion(tc.content, tc.signature, tc.keys, tc.version)
+		if tc.expectedError == "" {
+			assert.Nil(t, err, tc.name)
+		} else {
+			assert.NotNil(t, err, tc.name)
+			if err != nil {
+				assert.Equal(t, tc.expectedError, err.Error())
+			}
+		}
+	}
+}
+
+func startStoreServer(t *testing.T, version FullVersionInfo, keys []MaintainerKey) *AppStoreClient {
+	mux := http.NewServeMux()
+	mux.HandleFunc(DownloadPath, func(w http.ResponseWriter, r *http.Request) {
+		utils.SendJsonResponse(w, version)
+	})
+	mux.HandleFunc(GetKeysPath, func(w http.ResponseWriter, r *http.Request) {
+		utils.SendJsonResponse(w, keys)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return &AppStoreClient{Parent: utils.ComponentClient{RootUrl: server.URL}}
+}
+
+func TestDownloadVersionVerifiesSignature(t *testing.T) {
+	content, err := validation.ZipDirectory("../validation/samples/test-compose-files/allow-app-yml")
+	assert.Nil(t, err)
+	key := testSigningKey("samplemaintainer")
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	signature := SignVersion(content, VersionMetadata{Maintainer: "samplemaintainer", App: "gitea", Version: "1.0", CreatedAt: createdAt}, key)
+	version := FullVersionInfo{VersionName: "1.0", Maintainer: "samplemaintainer", AppName: "gitea", Content: content, VersionCreationTimestamp: createdAt, Signature: &signature}
+	keys := []MaintainerKey{publishedKey(key, nil)}
+
+	downloaded, err := startStoreServer(t, version, keys).DownloadVersion("1")
+	assert.Nil(t, err)
+	if downloaded != nil {
+		assert.Equal(t, content, downloaded.Content)
+	}
+
+	tampered := version
+	tampered.Content = append([]byte{}, content...)
+	tampered.Content[len(tampered.Content)-1] ^= 0xff
+	_, err = startStoreServer(t, tampered, keys).DownloadVersion("1")
+	assert.NotNil(t, err)
+	if err != nil {
+		assert.Equal(t, "version verification failed: digest of version does not match, expected "+signature.Digest+" but got "+Digest(tampered.Content), err.Error())
+	}
+
+	unsigned := version
+	unsigned.Signature = nil
+	client := startStoreServer(t, unsigned, keys)
+	_, err = client.DownloadVersion("1")
+	assert.Nil(t, err)
+	client.RequireSignedVersions = true
+	_, err = client.DownloadVersion("1")
+	assert.NotNil(t, err)
+	if err != nil {
+		assert.Equal(t, "version verification failed: version is not signed", err.Error())
+	}
+}
+
+func TestUploadVersionSignsMetadata(t *testing.T) {
+	key := testSigningKey("samplemaintainer")
+	var upload VersionUpload
+	mux := http.NewServeMux()
+	mux.HandleFunc(AppGetListPath, func(w http.ResponseWriter, r *http.Request) {
+		utils.SendJsonResponse(w, []App{{Maintainer: "samplemaintainer", Name: "gitea", Id: "3"}})
+	})
+	mux.HandleFunc(VersionUploadPath, func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&upload))
+	})
+	mux.HandleFunc(GetVersionsPath, func(w http.ResponseWriter, r *http.Request) {
+		utils.SendJsonResponse(w, []Version{{Name: "1.0", Id: "7"}})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client := &AppStoreClient{Parent: utils.ComponentClient{RootUrl: server.URL}, SigningKey: key}
+
+	versionId, err := client.UploadVersion("3", "1.0", []byte("content"))
+	assert.Nil(t, err)
+	assert.Equal(t, "7", versionId)
+	assert.NotNil(t, upload.Signature)
+	if upload.Signature != nil {
+		version := VersionMetadata{Maintainer: "samplemaintainer", App: "gitea", Version: "1.0", CreatedAt: upload.Signature.CreatedAt}
+		assert.Nil(t, VerifyVersion([]byte("content"), *upload.Signature, []MaintainerKey{publishedKey(key, nil)}, version))
+	}
+
+	_, err = client.UploadVersion("4", "1.0", []byte("content"))
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
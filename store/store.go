@@ -1,9 +1,13 @@
 package store
 
 import (
+	"context"
+	"crypto/ed25519"
 	"fmt"
 	"github.com/ocelot-cloud/shared/utils"
 	"github.com/ocelot-cloud/shared/validation"
+	"net/http"
+	"time"
 )
 
 var (
@@ -25,6 +29,11 @@ var (
 	GetVersionsPath   = VersionPath + "/list"
 	DownloadPath      = VersionPath + "/download"
 
+	KeyPath       = ApiPrefix + "/keys"
+	KeyUploadPath = KeyPath + "/upload"
+	KeyRevokePath = KeyPath + "/revoke"
+	GetKeysPath   = KeyPath + "/list"
+
 	AppPath         = ApiPrefix + "/apps"
 	AppCreationPath = AppPath + "/create"
 	AppGetListPath  = AppPath + "/get-list"
@@ -34,36 +43,66 @@ var (
 	DefaultValidationCode = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
 )
 
+// IdempotentPaths only read data, so that requests to them can safely be retried, for example with
+// utils.DefaultRetryPolicy(IdempotentPaths...).
+var IdempotentPaths = []string{AuthCheckPath, AppGetListPath, SearchAppsPath, GetVersionsPath, DownloadPath, GetKeysPath}
+
+func (h *AppStoreClient) doRequest(ctx context.Context, path string, payload interface{}) ([]byte, error) {
+	result, err := h.Parent.DoRequestContext(ctx, path, payload)
+	return result, toDomainError(err)
+}
+
+func (h *AppStoreClient) doRequestWithFullResponse(ctx context.Context, path string, payload interface{}) (*http.Response, error) {
+	resp, err := h.Parent.DoRequestWithFullResponseContext(ctx, path, payload)
+	return resp, toDomainError(err)
+}
+
 func (h *AppStoreClient) RegisterAndValidateUser(user, password, email string) error {
-	err := h.RegisterUser(user, password, email)
+	return h.RegisterAndValidateUserContext(context.Background(), user, password, email)
+}
+
+func (h *AppStoreClient) RegisterAndValidateUserContext(ctx context.Context, user, password, email string) error {
+	err := h.RegisterUserContext(ctx, user, password, email)
 	if err != nil {
 		return err
 	}
-	return h.ValidateCode()
+	return h.ValidateCodeContext(ctx)
 }
 
 func (h *AppStoreClient) RegisterUser(user, password, email string) error {
+	return h.RegisterUserContext(context.Background(), user, password, email)
+}
+
+func (h *AppStoreClient) RegisterUserContext(ctx context.Context, user, password, email string) error {
 	form := RegistrationForm{
 		User:     user,
 		Password: password,
 		Email:    email,
 	}
-	_, err := h.Parent.DoRequest(RegistrationPath, form)
+	_, err := h.doRequest(ctx, RegistrationPath, form)
 	return err
 }
 
 func (h *AppStoreClient) ValidateCode() error {
-	_, err := h.Parent.DoRequest(EmailValidationPath+"?code="+DefaultValidationCode, nil)
+	return h.ValidateCodeContext(context.Background())
+}
+
+func (h *AppStoreClient) ValidateCodeContext(ctx context.Context) error {
+	_, err := h.doRequest(ctx, EmailValidationPath+"?code="+DefaultValidationCode, nil)
 	return err
 }
 
 func (h *AppStoreClient) Login(username, password string) error {
+	return h.LoginContext(context.Background(), username, password)
+}
+
+func (h *AppStoreClient) LoginContext(ctx context.Context, username, password string) error {
 	creds := LoginCredentials{
 		User:     username,
 		Password: password,
 	}
 
-	resp, err := h.Parent.DoRequestWithFullResponse(LoginPath, creds)
+	resp, err := h.doRequestWithFullResponse(ctx, LoginPath, creds)
 	if err != nil {
 		return err
 	}
@@ -72,21 +111,29 @@ func (h *AppStoreClient) Login(username, password string) error {
 	if len(cookies) != 1 {
 		return fmt.Errorf("Expected 1 cookie, got %d", len(cookies))
 	}
-	h.Parent.Cookie = cookies[0]
+	h.Parent.SetCookie(cookies[0])
 	return nil
 }
 
 func (h *AppStoreClient) DeleteUser() error {
-	_, err := h.Parent.DoRequest(DeleteUserPath, nil)
+	return h.DeleteUserContext(context.Background())
+}
+
+func (h *AppStoreClient) DeleteUserContext(ctx context.Context) error {
+	_, err := h.doRequest(ctx, DeleteUserPath, nil)
 	return err
 }
 
 func (h *AppStoreClient) CreateApp(appName string) (string, error) {
-	_, err := h.Parent.DoRequest(AppCreationPath, AppNameString{appName})
+	return h.CreateAppContext(context.Background(), appName)
+}
+
+func (h *AppStoreClient) CreateAppContext(ctx context.Context, appName string) (string, error) {
+	_, err := h.doRequest(ctx, AppCreationPath, AppNameString{appName})
 	if err != nil {
 		return "", err
 	}
-	appsInStore, err := h.ListOwnApps()
+	appsInStore, err := h.ListOwnAppsContext(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -95,15 +142,19 @@ func (h *AppStoreClient) CreateApp(appName string) (string, error) {
 			return appInStore.Id, nil
 		}
 	}
-	return "", fmt.Errorf("app not found on server")
+	return "", &domainError{ErrNotFound, fmt.Errorf("app not found on server")}
 }
 
 func (h *AppStoreClient) SearchForApps(searchTerm string, showUnofficialApps bool) ([]AppWithLatestVersion, error) {
+	return h.SearchForAppsContext(context.Background(), searchTerm, showUnofficialApps)
+}
+
+func (h *AppStoreClient) SearchForAppsContext(ctx context.Context, searchTerm string, showUnofficialApps bool) ([]AppWithLatestVersion, error) {
 	appSearchRequest := AppSearchRequest{
 		SearchTerm:         searchTerm,
 		ShowUnofficialApps: showUnofficialApps,
 	}
-	result, err := h.Parent.DoRequest(SearchAppsPath, appSearchRequest)
+	result, err := h.doRequest(ctx, SearchAppsPath, appSearchRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +168,11 @@ func (h *AppStoreClient) SearchForApps(searchTerm string, showUnofficialApps boo
 }
 
 func (h *AppStoreClient) ListOwnApps() ([]App, error) {
-	result, err := h.Parent.DoRequest(AppGetListPath, nil)
+	return h.ListOwnAppsContext(context.Background())
+}
+
+func (h *AppStoreClient) ListOwnAppsContext(ctx context.Context) ([]App, error) {
+	result, err := h.doRequest(ctx, AppGetListPath, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -131,17 +186,29 @@ func (h *AppStoreClient) ListOwnApps() ([]App, error) {
 }
 
 func (h *AppStoreClient) UploadVersion(appId, versionName string, content []byte) (string, error) {
+	return h.UploadVersionContext(context.Background(), appId, versionName, content)
+}
+
+func (h *AppStoreClient) UploadVersionContext(ctx context.Context, appId, versionName string, content []byte) (string, error) {
 	tapUpload := &VersionUpload{
 		AppId:   appId,
 		Version: versionName,
 		Content: content,
 	}
-	_, err := h.Parent.DoRequest(VersionUploadPath, tapUpload)
+	if h.SigningKey != nil {
+		metadata, err := h.versionMetadata(ctx, appId, versionName)
+		if err != nil {
+			return "", err
+		}
+		signature := SignVersion(content, metadata, h.SigningKey)
+		tapUpload.Signature = &signature
+	}
+	_, err := h.doRequest(ctx, VersionUploadPath, tapUpload)
 	if err != nil {
 		return "", err
 	}
 
-	versionsInStore, err := h.GetVersions(appId)
+	versionsInStore, err := h.GetVersionsContext(ctx, appId)
 	if err != nil {
 		return "", err
 	}
@@ -150,11 +217,29 @@ func (h *AppStoreClient) UploadVersion(appId, versionName string, content []byte
 			return versionInStore.Id, nil
 		}
 	}
-	return "", fmt.Errorf("version not found on server")
+	return "", &domainError{ErrNotFound, fmt.Errorf("version not found on server")}
+}
+
+// versionMetadata looks up the names of the app and its maintainer, which are signed along with the version.
+func (h *AppStoreClient) versionMetadata(ctx context.Context, appId, versionName string) (VersionMetadata, error) {
+	apps, err := h.ListOwnAppsContext(ctx)
+	if err != nil {
+		return VersionMetadata{}, err
+	}
+	for _, app := range apps {
+		if app.Id == appId {
+			return VersionMetadata{Maintainer: app.Maintainer, App: app.Name, Version: versionName, CreatedAt: time.Now().UTC()}, nil
+		}
+	}
+	return VersionMetadata{}, &domainError{ErrNotFound, fmt.Errorf("app %s not found", appId)}
 }
 
 func (h *AppStoreClient) DownloadVersion(versionId string) (*FullVersionInfo, error) {
-	result, err := h.Parent.DoRequest(DownloadPath, NumberString{versionId})
+	return h.DownloadVersionContext(context.Background(), versionId)
+}
+
+func (h *AppStoreClient) DownloadVersionContext(ctx context.Context, versionId string) (*FullVersionInfo, error) {
+	result, err := h.doRequest(ctx, DownloadPath, NumberString{versionId})
 	if err != nil {
 		return nil, err
 	}
@@ -164,6 +249,11 @@ func (h *AppStoreClient) DownloadVersion(versionId string) (*FullVersionInfo, er
 		return nil, err
 	}
 
+	err = h.verifyVersion(ctx, fullVersionInfo)
+	if err != nil {
+		return nil, fmt.Errorf("version verification failed: %w", err)
+	}
+
 	err = validation.ValidateVersion(fullVersionInfo.Content, fullVersionInfo.Maintainer, fullVersionInfo.AppName)
 	if err != nil {
 		return nil, fmt.Errorf("version validation failed: %w", err)
@@ -172,8 +262,68 @@ func (h *AppStoreClient) DownloadVersion(versionId string) (*FullVersionInfo, er
 	return fullVersionInfo, nil
 }
 
+func (h *AppStoreClient) verifyVersion(ctx context.Context, version *FullVersionInfo) error {
+	if version.Signature == nil {
+		if h.RequireSignedVersions {
+			return fmt.Errorf("version is not signed")
+		}
+		return nil
+	}
+	keys, err := h.GetMaintainerKeysContext(ctx, version.Maintainer)
+	if err != nil {
+		return err
+	}
+	metadata := VersionMetadata{
+		Maintainer: version.Maintainer,
+		App:        version.AppName,
+		Version:    version.VersionName,
+		CreatedAt:  version.VersionCreationTimestamp,
+	}
+	return VerifyVersion(version.Content, *version.Signature, keys, metadata)
+}
+
+func (h *AppStoreClient) UploadPublicKey(publicKey ed25519.PublicKey) error {
+	return h.UploadPublicKeyContext(context.Background(), publicKey)
+}
+
+func (h *AppStoreClient) UploadPublicKeyContext(ctx context.Context, publicKey ed25519.PublicKey) error {
+	_, err := h.doRequest(ctx, KeyUploadPath, PublicKeyUpload{publicKey})
+	return err
+}
+
+func (h *AppStoreClient) RevokeKey(keyId string, revokedAt time.Time) error {
+	return h.RevokeKeyContext(context.Background(), keyId, revokedAt)
+}
+
+func (h *AppStoreClient) RevokeKeyContext(ctx context.Context, keyId string, revokedAt time.Time) error {
+	_, err := h.doRequest(ctx, KeyRevokePath, KeyRevocation{KeyId: keyId, RevokedAt: revokedAt})
+	return err
+}
+
+func (h *AppStoreClient) GetMaintainerKeys(maintainer string) ([]MaintainerKey, error) {
+	return h.GetMaintainerKeysContext(context.Background(), maintainer)
+}
+
+func (h *AppStoreClient) GetMaintainerKeysContext(ctx context.Context, maintainer string) ([]MaintainerKey, error) {
+	result, err := h.doRequest(ctx, GetKeysPath, MaintainerString{maintainer})
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := utils.UnpackResponse[[]MaintainerKey](result)
+	if err != nil {
+		return nil, err
+	}
+
+	return *keys, nil
+}
+
 func (h *AppStoreClient) GetVersions(appId string) ([]Version, error) {
-	result, err := h.Parent.DoRequest(GetVersionsPath, NumberString{appId})
+	return h.GetVersionsContext(context.Background(), appId)
+}
+
+func (h *AppStoreClient) GetVersionsContext(ctx context.Context, appId string) ([]Version, error) {
+	result, err := h.doRequest(ctx, GetVersionsPath, NumberString{appId})
 	if err != nil {
 		return nil, err
 	}
@@ -187,38 +337,62 @@ func (h *AppStoreClient) GetVersions(appId string) ([]Version, error) {
 }
 
 func (h *AppStoreClient) DeleteVersion(versionId string) error {
-	_, err := h.Parent.DoRequest(VersionDeletePath, NumberString{versionId})
+	return h.DeleteVersionContext(context.Background(), versionId)
+}
+
+func (h *AppStoreClient) DeleteVersionContext(ctx context.Context, versionId string) error {
+	_, err := h.doRequest(ctx, VersionDeletePath, NumberString{versionId})
 	return err
 }
 
 func (h *AppStoreClient) DeleteApp(appId string) error {
-	_, err := h.Parent.DoRequest(AppDeletePath, NumberString{appId})
+	return h.DeleteAppContext(context.Background(), appId)
+}
+
+func (h *AppStoreClient) DeleteAppContext(ctx context.Context, appId string) error {
+	_, err := h.doRequest(ctx, AppDeletePath, NumberString{appId})
 	return err
 }
 
 func (h *AppStoreClient) ChangePassword(oldPassword, newPassword string) error {
+	return h.ChangePasswordContext(context.Background(), oldPassword, newPassword)
+}
+
+func (h *AppStoreClient) ChangePasswordContext(ctx context.Context, oldPassword, newPassword string) error {
 	form := ChangePasswordForm{
 		OldPassword: oldPassword,
 		NewPassword: newPassword,
 	}
 
-	_, err := h.Parent.DoRequest(ChangePasswordPath, form)
+	_, err := h.doRequest(ctx, ChangePasswordPath, form)
 	return err
 }
 
 func (h *AppStoreClient) WipeData() {
-	_, err := h.Parent.DoRequest(WipeDataPath, nil)
+	h.WipeDataContext(context.Background())
+}
+
+func (h *AppStoreClient) WipeDataContext(ctx context.Context) {
+	_, err := h.doRequest(ctx, WipeDataPath, nil)
 	if err != nil {
 		panic("failed to wipe data: " + err.Error())
 	}
 }
 
 func (h *AppStoreClient) Logout() error {
-	_, err := h.Parent.DoRequest(LogoutPath, nil)
+	return h.LogoutContext(context.Background())
+}
+
+func (h *AppStoreClient) LogoutContext(ctx context.Context) error {
+	_, err := h.doRequest(ctx, LogoutPath, nil)
 	return err
 }
 
 func (h *AppStoreClient) CheckAuth() error {
-	_, err := h.Parent.DoRequest(AuthCheckPath, nil)
+	return h.CheckAuthContext(context.Background())
+}
+
+func (h *AppStoreClient) CheckAuthContext(ctx context.Context) error {
+	_, err := h.doRequest(ctx, AuthCheckPath, nil)
 	return err
 }
@@ -10,5 +10,11 @@ go run .
 cd "$PROJECT_DIR/utils"
 go test .
 
+cd "$PROJECT_DIR/compose"
+go test .
+
 cd "$PROJECT_DIR/validation"
+go test .
+
+cd "$PROJECT_DIR/store"
 go test .
\ No newline at end of file
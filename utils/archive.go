@@ -0,0 +1,391 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SymlinkPolicy decides how symbolic links in archives are handled.
+type SymlinkPolicy int
+
+const (
+	// RejectSymlinks fails the extraction when the archive contains a symbolic link.
+	RejectSymlinks SymlinkPolicy = iota
+	// SkipSymlinks leaves out symbolic links.
+	SkipSymlinks
+	// AllowSymlinksWithinDestination creates symbolic links, as long as they point into the destination directory.
+	AllowSymlinksWithinDestination
+)
+
+// UnzipOptions restricts what is extracted from an archive. Zero values of the limits mean no limit, so callers
+// handling untrusted archives should start from DefaultUnzipOptions.
+type UnzipOptions struct {
+	// Destination is the directory the files are extracted to. A temporary directory is created if it is empty.
+	Destination string
+	// MaxArchiveBytes limits the size of the archive itself.
+	MaxArchiveBytes int64
+	MaxFiles        int
+	// MaxTotalBytes limits the size of all extracted files together.
+	MaxTotalBytes int64
+	MaxFileBytes  int64
+	// MaxCompressionRatio limits the uncompressed size of each file relative to its compressed size to detect zip bombs.
+	MaxCompressionRatio float64
+	// AllowedFileModes are the permission bits files may have after the dangerous bits were stripped, files with
+	// other permission bits are rejected.
+	AllowedFileModes fs.FileMode
+	Symlinks         SymlinkPolicy
+	// Progress is called while the archive is extracted, it may be nil.
+	Progress ProgressFunc
+}
+
+// DefaultUnzipOptions returns the limits for uploaded app versions, which are used by UnzipToTempDir and OpenZip.
+func DefaultUnzipOptions() UnzipOptions {
+	return UnzipOptions{
+		MaxArchiveBytes:     10 * 1024 * 1024,
+		MaxFiles:            100,
+		MaxTotalBytes:       10 * 1024 * 1024, // 10 MB
+		MaxFileBytes:        10 * 1024 * 1024,
+		MaxCompressionRatio: 100,
+		AllowedFileModes:    0777,
+		Symlinks:            RejectSymlinks,
+	}
+}
+
+// UnzipToTempDir unzips the given zip bytes to a temporary directory and returns the path to the directory.
+func UnzipToTempDir(zipBytes []byte) (string, error) {
+	return Unzip(context.Background(), zipBytes, DefaultUnzipOptions())
+}
+
+// Unzip extracts the archive according to the options and returns the destination directory. The extraction stops
+// when the context is cancelled. A temporary destination is removed again if the extraction fails.
+func Unzip(ctx context.Context, zipBytes []byte, options UnzipOptions) (string, error) {
+	zipReader, err := openZipReader(bytes.NewReader(zipBytes), int64(len(zipBytes)), options)
+	if err != nil {
+		return "", err
+	}
+	return unzipReader(ctx, zipReader, options)
+}
+
+func unzipReader(ctx context.Context, zipReader *zip.Reader, options UnzipOptions) (string, error) {
+	dest, cleanup, err := prepareDestination(options)
+	if err != nil {
+		return "", err
+	}
+	if err := extractZip(ctx, zipReader, dest, options); err != nil {
+		cleanup()
+		if options.Destination == "" {
+			dest = ""
+		}
+		return dest, err
+	}
+	return dest, nil
+}
+
+// OpenZip returns the files of the zip as read-only file system without extracting them to disk. The
+// DefaultUnzipOptions apply.
+func OpenZip(zipBytes []byte) (fs.FS, error) {
+	return OpenZipWithOptions(zipBytes, DefaultUnzipOptions())
+}
+
+// OpenZipWithOptions returns the files of the zip as read-only file system like OpenZip. Symbolic links are never
+// followed, the SkipSymlinks and AllowSymlinksWithinDestination policies only keep them from failing the check.
+func OpenZipWithOptions(zipBytes []byte, options UnzipOptions) (fs.FS, error) {
+	return openZipReader(bytes.NewReader(zipBytes), int64(len(zipBytes)), options)
+}
+
+// openZipReader checks the headers of all files against the options before anything is read. The zip reader fails
+// when a file contains more data than declared in its header, so the declared sizes can be trusted.
+func openZipReader(r io.ReaderAt, size int64, options UnzipOptions) (*zip.Reader, error) {
+	if options.MaxArchiveBytes > 0 && size > options.MaxArchiveBytes {
+		return nil, fmt.Errorf("archive exceeds the size limit of %d bytes", options.MaxArchiveBytes)
+	}
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip file: %v", err)
+	}
+	if options.MaxFiles > 0 && len(zipReader.File) > options.MaxFiles {
+		return nil, fmt.Errorf("too many files in zip: %d, max allowed: %d", len(zipReader.File), options.MaxFiles)
+	}
+	var totalUnpacked uint64
+	for _, file := range zipReader.File {
+		if err := checkZipEntry(file, options); err != nil {
+			return nil, err
+		}
+		totalUnpacked += file.UncompressedSize64
+		if options.MaxTotalBytes > 0 && totalUnpacked > uint64(options.MaxTotalBytes) {
+			return nil, fmt.Errorf("unpacked data exceeds limit")
+		}
+	}
+	return zipReader, nil
+}
+
+func checkZipEntry(file *zip.File, options UnzipOptions) error {
+	if err := checkEntry("zip", file.Name, file.Mode(), file.UncompressedSize64, options); err != nil {
+		return err
+	}
+	if file.Mode().IsRegular() && options.MaxCompressionRatio > 0 && file.UncompressedSize64 > 0 {
+		ratio := float64(file.UncompressedSize64) / float64(max(file.CompressedSize64, 1))
+		if ratio > options.MaxCompressionRatio {
+			return fmt.Errorf("compression ratio of %s in zip exceeds the limit of %v", file.Name, options.MaxCompressionRatio)
+		}
+	}
+	return nil
+}
+
+// checkEntry checks the name, the mode and the size of an entry of an archive of the given kind, like "zip" or "tar".
+func checkEntry(kind, name string, mode fs.FileMode, size uint64, options UnzipOptions) error {
+	if _, err := cleanEntryName(kind, name); err != nil {
+		return err
+	}
+	if mode&fs.ModeSymlink != 0 {
+		if options.Symlinks == RejectSymlinks {
+			return fmt.Errorf("symlinks are not allowed in %s: %s", kind, name)
+		}
+		return nil
+	}
+	if mode.IsDir() {
+		return nil
+	}
+	if !mode.IsRegular() {
+		return fmt.Errorf("file mode %v of %s is not allowed in %s", mode, name, kind)
+	}
+	if options.AllowedFileModes != 0 && safeFileMode(mode)&^options.AllowedFileModes != 0 {
+		return fmt.Errorf("file mode %v of %s is not allowed in %s", safeFileMode(mode), name, kind)
+	}
+	if options.MaxFileBytes > 0 && size > uint64(options.MaxFileBytes) {
+		return fmt.Errorf("file %s in %s exceeds the size limit of %d bytes", name, kind, options.MaxFileBytes)
+	}
+	return nil
+}
+
+func createTempDir() (string, error) {
+	tempDir, err := os.MkdirTemp("", "temp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %v", err)
+	}
+	return tempDir, nil
+}
+
+// dangerousFileModeBits are stripped from extracted files, so that archives can neither create setuid binaries nor
+// files other users may modify.
+const dangerousFileModeBits = fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky | 0002
+
+func safeFileMode(mode fs.FileMode) fs.FileMode {
+	return mode.Perm() &^ dangerousFileModeBits
+}
+
+// cleanEntryName returns the name of the archive entry as clean relative path. Names which are absolute, contain a
+// volume name or escape the destination after cleaning are rejected. Backslashes are treated as separators, since
+// some Windows tools write them.
+func cleanEntryName(kind, name string) (string, error) {
+	normalized := strings.ReplaceAll(name, "\\", "/")
+	if normalized == "" || strings.ContainsRune(normalized, 0) || strings.HasPrefix(normalized, "/") || isWindowsVolume(normalized) {
+		return "", fmt.Errorf("invalid file path in %s: %s", kind, name)
+	}
+	cleaned := path.Clean(normalized)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid file path in %s: %s", kind, name)
+	}
+	return cleaned, nil
+}
+
+func isWindowsVolume(name string) bool {
+	return len(name) >= 2 && name[1] == ':' && ((name[0] >= 'a' && name[0] <= 'z') || (name[0] >= 'A' && name[0] <= 'Z'))
+}
+
+// entryPath returns the path the entry is extracted to. It fails if the path is not within the destination or if
+// one of the directories on the way is a symbolic link, which could redirect the write outside of the destination.
+func entryPath(kind, dest, name string) (string, error) {
+	cleaned, err := cleanEntryName(kind, name)
+	if err != nil {
+		return "", err
+	}
+	fpath := filepath.Join(dest, filepath.FromSlash(cleaned))
+	if !isWithinDir(dest, fpath) {
+		return "", fmt.Errorf("invalid file path in %s: %s", kind, name)
+	}
+	current := dest
+	for _, element := range strings.Split(cleaned, "/") {
+		current = filepath.Join(current, element)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("file path %s in %s traverses a symlink", name, kind)
+		}
+	}
+	return fpath, nil
+}
+
+// prepareDestination returns the destination of the options or creates a temporary directory. The returned cleanup
+// function removes a temporary directory again, it is called when the extraction fails.
+func prepareDestination(options UnzipOptions) (string, func(), error) {
+	if options.Destination != "" {
+		return options.Destination, func() {}, nil
+	}
+	dest, err := createTempDir()
+	if err != nil {
+		return "", nil, err
+	}
+	return dest, func() { RemoveDir(dest) }, nil
+}
+
+func extractZip(ctx context.Context, zipReader *zip.Reader, dest string, options UnzipOptions) error {
+	e := newExtraction(ctx, "zip", dest, options)
+	for _, file := range zipReader.File {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("extraction cancelled: %w", err)
+		}
+		if err := extractZipEntry(e, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipEntry(e *extraction, file *zip.File) error {
+	mode := file.Mode()
+	if mode&fs.ModeSymlink != 0 && e.options.Symlinks != AllowSymlinksWithinDestination {
+		return nil
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer Close(rc)
+	if mode&fs.ModeSymlink != 0 {
+		target, err := io.ReadAll(io.LimitReader(rc, maxSymlinkTargetLength))
+		if err != nil {
+			return err
+		}
+		return e.writeSymlink(file.Name, string(target))
+	}
+	return e.writeEntry(file.Name, mode, rc)
+}
+
+// extraction writes the entries of an archive of the given kind, like "zip" or "tar", to the destination. It counts
+// the unpacked bytes against MaxTotalBytes and reports the progress.
+type extraction struct {
+	ctx           context.Context
+	kind          string
+	dest          string
+	options       UnzipOptions
+	totalUnpacked int64
+	progress      *progressTracker
+}
+
+func newExtraction(ctx context.Context, kind, dest string, options UnzipOptions) *extraction {
+	return &extraction{ctx: ctx, kind: kind, dest: dest, options: options, progress: newProgressTracker(options.Progress)}
+}
+
+// writeEntry writes a directory or regular file of an archive to the destination.
+func (e *extraction) writeEntry(name string, mode fs.FileMode, content io.Reader) error {
+	fpath, err := entryPath(e.kind, e.dest, name)
+	if err != nil {
+		return err
+	}
+	e.progress.startEntry(name)
+
+	if mode.IsDir() {
+		return os.MkdirAll(fpath, 0700)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fpath), 0700); err != nil {
+		return err
+	}
+
+	outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, safeFileMode(mode)) // #nosec G304 (CWE-22): File inclusion via variable path; checked by entryPath
+	if err != nil {
+		return err
+	}
+	defer Close(outFile)
+
+	// #nosec G110 (CWE-409): DoS risk via zip bomb mitigated by max unpack limit
+	_, err = io.Copy(outFile, e.progress.reader(limitedCounter{contextReader{e.ctx, content}, &e.totalUnpacked, e.options.MaxTotalBytes}))
+	return err
+}
+
+const maxSymlinkTargetLength = 4096
+
+// writeSymlink creates the symbolic link, as long as its target is a relative path within the destination.
+func (e *extraction) writeSymlink(name, target string) error {
+	linkPath, err := entryPath(e.kind, e.dest, name)
+	if err != nil {
+		return err
+	}
+	resolved := filepath.Join(filepath.Dir(linkPath), target)
+	if target == "" || filepath.IsAbs(target) || isWindowsVolume(target) || !isWithinDir(e.dest, resolved) {
+		return fmt.Errorf("symlink %s in %s points outside of the destination: %s", name, e.kind, target)
+	}
+	if !hasOnlyLeadingParentElements(target) {
+		return fmt.Errorf("symlink %s in %s must only use '..' at the start of its target: %s", name, e.kind, target)
+	}
+	e.progress.startEntry(name)
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0700); err != nil {
+		return err
+	}
+	return os.Symlink(target, linkPath)
+}
+
+// hasOnlyLeadingParentElements tells whether '..' only appears at the start of the symlink target. The target is
+// resolved lexically above, which is only what the operating system does if no '..' follows an element which may be
+// a symlink itself. Otherwise chained symlinks like 'l1 -> ../..' and 'l2 -> l1/../../outside' escape the destination.
+func hasOnlyLeadingParentElements(target string) bool {
+	leading := true
+	for _, element := range strings.Split(filepath.ToSlash(target), "/") {
+		if element == ".." && !leading {
+			return false
+		}
+		if element != ".." && element != "." && element != "" {
+			leading = false
+		}
+	}
+	return true
+}
+
+func isWithinDir(dir, path string) bool {
+	relative, err := filepath.Rel(dir, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+type limitedCounter struct {
+	r     io.Reader
+	total *int64
+	limit int64
+}
+
+func (lc limitedCounter) Read(p []byte) (int, error) {
+	n, err := lc.r.Read(p)
+	if n > 0 {
+		if lc.limit > 0 && *lc.total+int64(n) > lc.limit {
+			return 0, fmt.Errorf("unpacked data exceeds limit")
+		}
+		*lc.total += int64(n)
+	}
+	return n, err
+}
+
+// contextReader stops reading when the context is cancelled, so that large files do not delay the cancellation.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, fmt.Errorf("extraction cancelled: %w", err)
+	}
+	return cr.r.Read(p)
+}
@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"github.com/ocelot-cloud/shared/assert"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type zipEntry struct {
+	name    string
+	mode    fs.FileMode
+	content string
+}
+
+func createZip(t *testing.T, entries ...zipEntry) []byte {
+	zipBytes, err := buildZip(entries...)
+	assert.Nil(t, err)
+	return zipBytes
+}
+
+func buildZip(entries ...zipEntry) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		header.SetMode(entry.mode)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write([]byte(entry.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func TestUnzipWithOptions(t *testing.T) {
+	zipBytes := createZip(t, zipEntry{"dir/file.txt", 0640, "hello"})
+	dest := t.TempDir()
+	result, err := Unzip(context.Background(), zipBytes, UnzipOptions{Destination: dest})
+	assert.Nil(t, err)
+	assert.Equal(t, dest, result)
+	content, err := os.ReadFile(filepath.Join(dest, "dir", "file.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(content))
+}
+
+func TestUnzipRejectsArchivesViolatingOptions(t *testing.T) {
+	large := string(bytes.Repeat([]byte("A"), 10000))
+	testCases := []struct {
+		name          string
+		entry         zipEntry
+		options       UnzipOptions
+		expectedError string
+	}{
+		{"file size", zipEntry{"a.txt", 0644, "hello"}, UnzipOptions{MaxFileBytes: 4}, "file a.txt in zip exceeds the size limit of 4 bytes"},
+		{"compression ratio", zipEntry{"a.txt", 0644, large}, UnzipOptions{MaxCompressionRatio: 10}, "compression ratio of a.txt in zip exceeds the limit of 10"},
+		{"device", zipEntry{"null", fs.ModeDevice | fs.ModeCharDevice | 0666, ""}, DefaultUnzipOptions(), "file mode Dcrw-rw-rw- of null is not allowed in zip"},
+		{"absolute path", zipEntry{"/etc/cron.d/job", 0644, "* * * * * root sh"}, DefaultUnzipOptions(), "invalid file path in zip: /etc/cron.d/job"},
+		{"windows path", zipEntry{"..\\..\\evil.txt", 0644, "evil"}, DefaultUnzipOptions(), "invalid file path in zip: ..\\..\\evil.txt"},
+		{"windows volume", zipEntry{"C:/evil.txt", 0644, "evil"}, DefaultUnzipOptions(), "invalid file path in zip: C:/evil.txt"},
+		{"cleaned traversal", zipEntry{"a/../../evil.txt", 0644, "evil"}, DefaultUnzipOptions(), "invalid file path in zip: a/../../evil.txt"},
+		{"permission", zipEntry{"a.sh", 0755, "#!/bin/sh"}, UnzipOptions{AllowedFileModes: 0644}, "file mode -rwxr-xr-x of a.sh is not allowed in zip"},
+		{"symlink", zipEntry{"link", fs.ModeSymlink | 0777, "/etc/passwd"}, DefaultUnzipOptions(), "symlinks are not allowed in zip: link"},
+		{"symlink outside", zipEntry{"link", fs.ModeSymlink | 0777, "../../etc/passwd"}, UnzipOptions{Symlinks: AllowSymlinksWithinDestination}, "symlink link in zip points outside of the destination: ../../etc/passwd"},
+	}
+
+	for _, tc := range testCases {
+		dir, err := Unzip(context.Background(), createZip(t, tc.entry), tc.options)
+		assert.NotNil(t, err, tc.name)
+		if err != nil {
+			assert.Equal(t, tc.expectedError, err.Error())
+		}
+		assert.Equal(t, "", dir)
+	}
+}
+
+func TestUnzipStripsDangerousModeBits(t *testing.T) {
+	zipBytes := createZip(t, zipEntry{"a.sh", 0777 | fs.ModeSetuid, "#!/bin/sh"}, zipEntry{"a..b.txt", 0644, "dots are fine"})
+	dest := t.TempDir()
+	_, err := Unzip(context.Background(), zipBytes, UnzipOptions{Destination: dest})
+	assert.Nil(t, err)
+	info, err := os.Stat(filepath.Join(dest, "a.sh"))
+	assert.Nil(t, err)
+	assert.Equal(t, fs.FileMode(0), info.Mode()&(fs.ModeSetuid|0002))
+	_, err = os.Stat(filepath.Join(dest, "a..b.txt"))
+	assert.Nil(t, err)
+}
+
+func TestUnzipRefusesWritesThroughSymlinks(t *testing.T) {
+	zipBytes := createZip(t, zipEntry{"dir", fs.ModeSymlink | 0777, "."}, zipEntry{"dir/file.txt", 0644, "hello"})
+	_, err := Unzip(context.Background(), zipBytes, UnzipOptions{Destination: t.TempDir(), Symlinks: AllowSymlinksWithinDestination})
+	assert.NotNil(t, err)
+	assert.Equal(t, "file path dir/file.txt in zip traverses a symlink", err.Error())
+}
+
+func TestUnzipRejectsChainedSymlinksLeavingTheDestination(t *testing.T) {
+	zipBytes := createZip(t,
+		zipEntry{"a/b/c/l1", fs.ModeSymlink | 0777, "../../.."},
+		zipEntry{"a/b/c/l2", fs.ModeSymlink | 0777, "l1/../../../../outside-secret"},
+	)
+	_, err := Unzip(context.Background(), zipBytes, UnzipOptions{Destination: t.TempDir(), Symlinks: AllowSymlinksWithinDestination})
+	assert.NotNil(t, err)
+	if err != nil {
+		assert.Equal(t, "symlink a/b/c/l2 in zip must only use '..' at the start of its target: l1/../../../../outside-secret", err.Error())
+	}
+}
+
+func TestUnzipSymlinkPolicies(t *testing.T) {
+	zipBytes := createZip(t, zipEntry{"file.txt", 0644, "hello"}, zipEntry{"link", fs.ModeSymlink | 0777, "file.txt"})
+
+	dest := t.TempDir()
+	_, err := Unzip(context.Background(), zipBytes, UnzipOptions{Destination: dest, Symlinks: SkipSymlinks})
+	assert.Nil(t, err)
+	_, err = os.Lstat(filepath.Join(dest, "link"))
+	assert.True(t, os.IsNotExist(err))
+
+	dest = t.TempDir()
+	_, err = Unzip(context.Background(), zipBytes, UnzipOptions{Destination: dest, Symlinks: AllowSymlinksWithinDestination})
+	assert.Nil(t, err)
+	target, err := os.Readlink(filepath.Join(dest, "link"))
+	assert.Nil(t, err)
+	assert.Equal(t, "file.txt", target)
+}
+
+func TestUnzipHonorsCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dir, err := Unzip(ctx, createZip(t, zipEntry{"a.txt", 0644, "hello"}), DefaultUnzipOptions())
+	assert.NotNil(t, err)
+	assert.Equal(t, "extraction cancelled: context canceled", err.Error())
+	assert.Equal(t, "", dir)
+}
+
+// FuzzUnzip checks that no archive can write outside of the destination, create symlinks pointing outside of it or
+// files with dangerous mode bits. The corpus in testdata contains known malicious archives.
+func FuzzUnzip(f *testing.F) {
+	seed, err := buildZip(zipEntry{"dir/file.txt", 0644, "hello"}, zipEntry{"dir/link", fs.ModeSymlink | 0777, "file.txt"})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(seed)
+	f.Fuzz(func(t *testing.T, zipBytes []byte) {
+		parent := t.TempDir()
+		dest := filepath.Join(parent, "dest")
+		assert.Nil(t, os.Mkdir(dest, 0700))
+		options := UnzipOptions{Destination: dest, MaxFiles: 20, MaxTotalBytes: 1024 * 1024, Symlinks: AllowSymlinksWithinDestination}
+		_, _ = Unzip(context.Background(), zipBytes, options)
+
+		entries, err := os.ReadDir(parent)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(entries))
+		err = filepath.WalkDir(dest, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := os.Lstat(path)
+			if err != nil {
+				return err
+			}
+			if info.Mode()&fs.ModeSymlink != 0 {
+				assert.True(t, isWithinDir(resolveSymlinks(dest), resolveSymlinks(path)), path)
+				return nil
+			}
+			assert.True(t, info.IsDir() || info.Mode().IsRegular(), path)
+			assert.Equal(t, fs.FileMode(0), info.Mode()&dangerousFileModeBits)
+			return nil
+		})
+		assert.Nil(t, err)
+	})
+}
+
+// resolveSymlinks resolves the absolute path like the operating system does, following every symlink before applying
+// a '..' after it. Unlike filepath.EvalSymlinks it also resolves links whose target does not exist.
+func resolveSymlinks(path string) string {
+	resolved := string(filepath.Separator)
+	elements := strings.Split(filepath.ToSlash(path), "/")
+	for hops := 0; len(elements) > 0 && hops < 255; {
+		element := elements[0]
+		elements = elements[1:]
+		if element == "" || element == "." {
+			continue
+		}
+		if element == ".." {
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, element)
+		target, err := os.Readlink(next)
+		if err != nil {
+			resolved = next
+			continue
+		}
+		hops++
+		if filepath.IsAbs(target) {
+			resolved = string(filepath.Separator)
+		}
+		elements = append(strings.Split(filepath.ToSlash(target), "/"), elements...)
+	}
+	return resolved
+}
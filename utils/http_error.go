@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// HTTPError is returned by ComponentClient when the server answers with an unexpected status code. Its message is the
+// same as the one of GetErrMsg.
+type HTTPError struct {
+	StatusCode int
+	// Body is the response body without trailing newline.
+	Body   string
+	Path   string
+	Method string
+}
+
+func newHTTPError(resp *http.Response, body []byte) *HTTPError {
+	httpError := &HTTPError{StatusCode: resp.StatusCode, Body: strings.TrimSuffix(string(body), "\n")}
+	if resp.Request != nil {
+		httpError.Method = resp.Request.Method
+		httpError.Path = resp.Request.URL.Path
+	}
+	return httpError
+}
+
+func (e *HTTPError) Error() string {
+	return GetErrMsg(e.StatusCode, e.Body)
+}
+
+// HasStatusCode tells whether the error is or wraps an HTTPError with the status code.
+func HasStatusCode(err error, statusCode int) bool {
+	var httpError *HTTPError
+	return errors.As(err, &httpError) && httpError.StatusCode == statusCode
+}
+
+func IsBadRequest(err error) bool {
+	return HasStatusCode(err, http.StatusBadRequest)
+}
+
+func IsUnauthorized(err error) bool {
+	return HasStatusCode(err, http.StatusUnauthorized)
+}
+
+func IsForbidden(err error) bool {
+	return HasStatusCode(err, http.StatusForbidden)
+}
+
+func IsNotFound(err error) bool {
+	return HasStatusCode(err, http.StatusNotFound)
+}
+
+func IsConflict(err error) bool {
+	return HasStatusCode(err, http.StatusConflict)
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"errors"
+	"github.com/ocelot-cloud/shared/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "app not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := (&ComponentClient{RootUrl: server.URL}).DoRequest("/apps/get", nil)
+	assert.NotNil(t, err)
+	if err == nil {
+		return
+	}
+	assert.Equal(t, "expected status code 200, but got 404. Response body: app not found", err.Error())
+
+	var httpError *HTTPError
+	assert.True(t, errors.As(err, &httpError))
+	assert.Equal(t, HTTPError{StatusCode: http.StatusNotFound, Body: "app not found", Path: "/apps/get", Method: "POST"}, *httpError)
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsUnauthorized(err))
+	assert.True(t, IsNotFound(errors.Join(errors.New("context"), err)))
+	assert.False(t, IsNotFound(errors.New("expected status code 200, but got 404.")))
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// archiveModTime is the modification time of all packed entries. It is the earliest time zip can represent.
+var archiveModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// packEntry is a file, directory or symbolic link to pack, with its name in the archive and normalized mode.
+type packEntry struct {
+	name string
+	path string
+	mode fs.FileMode
+	link string
+}
+
+// PackOptions configures packing a directory.
+type PackOptions struct {
+	// Progress is called while the directory is packed, it may be nil.
+	Progress ProgressFunc
+}
+
+// PackDirectory packs the content of the directory into an archive of the given format. The archive is reproducible:
+// entries are sorted by name, timestamps and owners are fixed and permissions are normalized to 0755 for directories
+// and executables and 0644 for other files. Packing identical content therefore always results in the same bytes, so
+// that archives can be identified by their hash. Symbolic links are stored as links and not followed.
+func PackDirectory(dirPath string, format ArchiveFormat) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := PackDirectoryTo(buf, dirPath, format, PackOptions{}); err != nil {
+		return nil, err
+	}
+	Logger.Info("packed files in directory with size", DirectoryField, dirPath, SizeInBytesField, buf.Len())
+	return buf.Bytes(), nil
+}
+
+// PackDirectoryTo writes the archive of PackDirectory to w while the files are read, so that large directories are
+// not held in memory. Nothing is written if the format is not supported.
+func PackDirectoryTo(w io.Writer, dirPath string, format ArchiveFormat, options PackOptions) error {
+	if format != ArchiveZip && format != ArchiveTar && format != ArchiveTarGz && format != ArchiveTarZst {
+		return fmt.Errorf("unsupported archive format: %s", format)
+	}
+	entries, err := collectPackEntries(dirPath)
+	if err != nil {
+		return err
+	}
+	progress := newProgressTracker(options.Progress)
+	if format == ArchiveZip {
+		return writeZip(w, entries, progress)
+	}
+	return writeTar(w, entries, format, progress)
+}
+
+// ZipDirectoryToBytes packs the directory into a reproducible zip archive, see PackDirectory.
+func ZipDirectoryToBytes(dirPath string) ([]byte, error) {
+	return PackDirectory(dirPath, ArchiveZip)
+}
+
+// ZipDirectoryTo writes the zip archive of ZipDirectoryToBytes to w, see PackDirectoryTo.
+func ZipDirectoryTo(w io.Writer, dirPath string, options PackOptions) error {
+	return PackDirectoryTo(w, dirPath, ArchiveZip, options)
+}
+
+func collectPackEntries(dirPath string) ([]packEntry, error) {
+	var entries []packEntry
+	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dirPath {
+			return nil
+		}
+		relPath, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entry := packEntry{name: filepath.ToSlash(relPath), path: path, mode: normalizedMode(info.Mode())}
+		switch {
+		case info.IsDir():
+			entry.name += "/"
+		case info.Mode()&fs.ModeSymlink != 0:
+			if entry.link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !info.Mode().IsRegular():
+			return fmt.Errorf("file mode %v of %s can not be packed", info.Mode(), relPath)
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, nil
+}
+
+func normalizedMode(mode fs.FileMode) fs.FileMode {
+	switch {
+	case mode.IsDir():
+		return fs.ModeDir | 0755
+	case mode&fs.ModeSymlink != 0:
+		return fs.ModeSymlink | 0777
+	case mode&0111 != 0:
+		return 0755
+	default:
+		return 0644
+	}
+}
+
+func writeZip(w io.Writer, entries []packEntry, progress *progressTracker) error {
+	zipWriter := zip.NewWriter(w)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Modified: archiveModTime}
+		header.SetMode(entry.mode)
+		if !entry.mode.IsDir() {
+			header.Method = zip.Deflate
+		}
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		progress.startEntry(entry.name)
+		switch {
+		case entry.mode&fs.ModeSymlink != 0:
+			// zip stores the target of a symbolic link as its content
+			_, err = io.WriteString(writer, entry.link)
+		case entry.mode.IsRegular():
+			err = writeFileContent(writer, entry.path, progress)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+func writeTar(w io.Writer, entries []packEntry, format ArchiveFormat, progress *progressTracker) error {
+	compressed, err := compress(w, format)
+	if err != nil {
+		return err
+	}
+	tarWriter := tar.NewWriter(compressed)
+	for _, entry := range entries {
+		header, err := tarHeader(entry)
+		if err != nil {
+			return err
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		progress.startEntry(entry.name)
+		if entry.mode.IsRegular() {
+			if err := writeFileContent(tarWriter, entry.path, progress); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+func tarHeader(entry packEntry) (*tar.Header, error) {
+	header := &tar.Header{Name: entry.name, Mode: int64(entry.mode.Perm()), ModTime: archiveModTime, Format: tar.FormatPAX}
+	switch {
+	case entry.mode.IsDir():
+		header.Typeflag = tar.TypeDir
+	case entry.mode&fs.ModeSymlink != 0:
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = entry.link
+	default:
+		info, err := os.Lstat(entry.path)
+		if err != nil {
+			return nil, err
+		}
+		header.Typeflag = tar.TypeReg
+		header.Size = info.Size()
+	}
+	return header, nil
+}
+
+func writeFileContent(w io.Writer, path string, progress *progressTracker) error {
+	file, err := os.Open(path) // #nosec G304 (CWE-22): Potential file inclusion via variable; the path comes from walking the directory to pack
+	if err != nil {
+		return err
+	}
+	defer Close(file)
+	_, err = io.Copy(w, progress.reader(file))
+	return err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// compress returns the compressor of the format. Gzip writes no name and timestamp and zstd runs single-threaded,
+// so that the output only depends on the input.
+func compress(w io.Writer, format ArchiveFormat) (io.WriteCloser, error) {
+	switch format {
+	case ArchiveTarGz:
+		return gzip.NewWriter(w), nil
+	case ArchiveTarZst:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
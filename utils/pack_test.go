@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"github.com/ocelot-cloud/shared/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSampleDirectory(t *testing.T, fileMode os.FileMode, modTime time.Time, names ...string) string {
+	dir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(path, []byte("content of "+name), fileMode))
+		assert.Nil(t, os.Chtimes(path, modTime, modTime))
+	}
+	assert.Nil(t, os.Symlink("a.txt", filepath.Join(dir, "link")))
+	return dir
+}
+
+func TestPackDirectoryIsReproducible(t *testing.T) {
+	first := writeSampleDirectory(t, 0600, time.Now(), "a.txt", "sub/b.txt", "c.txt")
+	second := writeSampleDirectory(t, 0640, time.Now().Add(-time.Hour), "c.txt", "sub/b.txt", "a.txt")
+
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTar, ArchiveTarGz, ArchiveTarZst} {
+		firstBytes, err := PackDirectory(first, format)
+		assert.Nil(t, err)
+		secondBytes, err := PackDirectory(second, format)
+		assert.Nil(t, err)
+		assert.True(t, bytes.Equal(firstBytes, secondBytes), string(format))
+	}
+}
+
+func TestPackDirectoryNormalizesPermissions(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh"), 0700))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "data.txt"), []byte("data"), 0600))
+
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTarGz} {
+		data, err := PackDirectory(dir, format)
+		assert.Nil(t, err)
+		dest, err := Unpack(context.Background(), data, DefaultUnzipOptions())
+		assert.Nil(t, err)
+		info, err := os.Stat(filepath.Join(dest, "run.sh"))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+		info, err = os.Stat(filepath.Join(dest, "data.txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+		RemoveDir(dest)
+	}
+}
+
+func TestPackDirectoryKeepsSymlinks(t *testing.T) {
+	dir := writeSampleDirectory(t, 0644, time.Now(), "a.txt")
+	data, err := PackDirectory(dir, ArchiveTar)
+	assert.Nil(t, err)
+	options := DefaultUnzipOptions()
+	options.Symlinks = AllowSymlinksWithinDestination
+	dest, err := Unpack(context.Background(), data, options)
+	assert.Nil(t, err)
+	defer RemoveDir(dest)
+	target, err := os.Readlink(filepath.Join(dest, "link"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a.txt", target)
+}
+
+func TestPackDirectoryToStreamsIntoUnpackFrom(t *testing.T) {
+	dir := writeSampleDirectory(t, 0644, time.Now(), "a.txt", "sub/b.txt")
+
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTarGz} {
+		var packed, unpacked []Progress
+		reader, writer := io.Pipe()
+		done := make(chan struct{})
+		go func() {
+			writer.CloseWithError(PackDirectoryTo(writer, dir, format, PackOptions{Progress: func(p Progress) { packed = append(packed, p) }}))
+			close(done)
+		}()
+
+		options := DefaultUnzipOptions()
+		options.Symlinks = SkipSymlinks
+		options.Progress = func(p Progress) { unpacked = append(unpacked, p) }
+		dest, err := UnpackFrom(context.Background(), reader, options)
+		assert.Nil(t, err)
+		content, err := os.ReadFile(filepath.Join(dest, "sub", "b.txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, "content of sub/b.txt", string(content))
+		RemoveDir(dest)
+		_, err = io.Copy(io.Discard, reader)
+		assert.Nil(t, err)
+		<-done
+
+		expectedBytes := int64(len("content of a.txt") + len("content of sub/b.txt"))
+		assert.Equal(t, Progress{Entry: "sub/b.txt", Entries: 4, Bytes: expectedBytes}, packed[len(packed)-1])
+		assert.Equal(t, Progress{Entry: "sub/b.txt", Entries: 3, Bytes: expectedBytes}, unpacked[len(unpacked)-1])
+	}
+}
+
+func TestUnpackFromRejectsTooLargeArchives(t *testing.T) {
+	dir := writeSampleDirectory(t, 0644, time.Now(), "a.txt")
+	expectedErrors := map[ArchiveFormat]string{
+		ArchiveZip: "archive exceeds the size limit of 300 bytes",
+		ArchiveTar: "failed to read tar file: archive exceeds the size limit of 300 bytes",
+	}
+	for format, expectedError := range expectedErrors {
+		buf := new(bytes.Buffer)
+		assert.Nil(t, PackDirectoryTo(buf, dir, format, PackOptions{}))
+		dest, err := UnpackFrom(context.Background(), buf, UnzipOptions{MaxArchiveBytes: 300})
+		assert.NotNil(t, err)
+		if err != nil {
+			assert.Equal(t, expectedError, err.Error())
+		}
+		assert.Equal(t, "", dest)
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import "io"
+
+// Progress tells how far packing or extracting an archive got.
+type Progress struct {
+	// Entry is the name of the file, directory or symbolic link which is currently processed.
+	Entry string
+	// Entries is the number of entries processed so far, including the current one.
+	Entries int
+	// Bytes is the size of the file contents processed so far, before compression.
+	Bytes int64
+}
+
+// ProgressFunc is called when an entry starts and while its content is processed.
+type ProgressFunc func(Progress)
+
+type progressTracker struct {
+	progress Progress
+	report   ProgressFunc
+}
+
+func newProgressTracker(report ProgressFunc) *progressTracker {
+	return &progressTracker{report: report}
+}
+
+func (pt *progressTracker) startEntry(name string) {
+	pt.progress.Entry = name
+	pt.progress.Entries++
+	pt.notify()
+}
+
+func (pt *progressTracker) notify() {
+	if pt.report != nil {
+		pt.report(pt.progress)
+	}
+}
+
+// reader reports the bytes read from r as progress of the current entry.
+func (pt *progressTracker) reader(r io.Reader) io.Reader {
+	if pt.report == nil {
+		return r
+	}
+	return progressReader{r, pt}
+}
+
+type progressReader struct {
+	r       io.Reader
+	tracker *progressTracker
+}
+
+func (pr progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if n > 0 {
+		pr.tracker.progress.Bytes += int64(n)
+		pr.tracker.notify()
+	}
+	return n, err
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IdempotencyKeyHeader carries a key which is the same for all attempts of a request, so that the server can detect
+// retries of requests it already processed.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryPolicy retries requests of a ComponentClient which failed because of network errors or one of the retryable
+// status codes. Only requests to the listed paths are retried, since retrying requests which are not idempotent, like
+// uploading a version, may apply them twice unless the server honors the IdempotencyKeyHeader.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one.
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt, it doubles with every further attempt up to MaxBackoff.
+	// The waits are randomized between half and the full backoff, so that clients do not retry at the same time.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// RetryableStatusCodes are the status codes of responses which are retried. A Retry-After header of these
+	// responses replaces the backoff.
+	RetryableStatusCodes []int
+	// MaxRetryAfter is the longest wait a Retry-After header may ask for, zero means MaxBackoff. The request is not
+	// retried when the server asks to wait longer, so that it cannot make the client sleep for hours.
+	MaxRetryAfter time.Duration
+	// Paths are the request paths to retry, query parameters are ignored when matching them.
+	Paths []string
+}
+
+// DefaultRetryPolicy returns a policy retrying requests to the paths three times within about two seconds, when the
+// server is unavailable or overloaded.
+func DefaultRetryPolicy(paths ...string) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+		},
+		Paths: paths,
+	}
+}
+
+// appliesTo tells whether requests to the path are retried. A nil policy applies to no path.
+func (p *RetryPolicy) appliesTo(path string) bool {
+	if p == nil || p.MaxAttempts <= 1 {
+		return false
+	}
+	path, _, _ = strings.Cut(path, "?")
+	for _, retriedPath := range p.Paths {
+		if retriedPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldRetry tells whether the attempt failed in a way a later attempt may succeed. Errors caused by the context of
+// the caller are never retried.
+func (p *RetryPolicy) shouldRetry(ctx context.Context, attempt int, resp *http.Response, err error) bool {
+	if attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	for _, statusCode := range p.RetryableStatusCodes {
+		if resp.StatusCode == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// wait returns how long to wait before the next attempt. It tells not to retry when the Retry-After header of the
+// response asks to wait longer than allowed.
+func (p *RetryPolicy) wait(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			maxRetryAfter := p.MaxRetryAfter
+			if maxRetryAfter <= 0 {
+				maxRetryAfter = p.MaxBackoff
+			}
+			return retryAfter, retryAfter <= maxRetryAfter
+		}
+	}
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0, true
+	}
+	// #nosec G404 (CWE-338): Use of weak random number generator; the jitter does not need to be unpredictable
+	return backoff/2 + rand.N(backoff/2+1), true
+}
+
+// parseRetryAfter parses the Retry-After header, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+func generateIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := cryptorand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"github.com/ocelot-cloud/shared/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startFlakyServer answers the first failures requests with the status code and the others with 200. It records the
+// idempotency keys of all attempts.
+func startFlakyServer(t *testing.T, failures, statusCode int, retryAfter string) (string, func() []string) {
+	var mutex sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		attempt := len(keys)
+		mutex.Unlock()
+		if attempt <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			http.Error(w, "unavailable", statusCode)
+			return
+		}
+		SendJsonResponse(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string{}, keys...)
+	}
+}
+
+func testRetryPolicy(paths ...string) *RetryPolicy {
+	policy := DefaultRetryPolicy(paths...)
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	return policy
+}
+
+func TestRetryWithSameIdempotencyKey(t *testing.T) {
+	url, keys := startFlakyServer(t, 2, http.StatusServiceUnavailable, "")
+	client := &ComponentClient{RootUrl: url, Retry: testRetryPolicy("/retried")}
+	_, err := client.DoRequest("/retried?query=1", nil)
+	assert.Nil(t, err)
+
+	recordedKeys := keys()
+	assert.Equal(t, 3, len(recordedKeys))
+	assert.Equal(t, 32, len(recordedKeys[0]))
+	assert.Equal(t, recordedKeys[0], recordedKeys[1])
+	assert.Equal(t, recordedKeys[0], recordedKeys[2])
+}
+
+func TestNoRetryForOtherPathsAndStatusCodes(t *testing.T) {
+	url, keys := startFlakyServer(t, 1, http.StatusServiceUnavailable, "")
+	client := &ComponentClient{RootUrl: url, Retry: testRetryPolicy("/retried")}
+	_, err := client.DoRequest("/upload", nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{""}, keys())
+
+	url, keys = startFlakyServer(t, 1, http.StatusBadRequest, "")
+	client = &ComponentClient{RootUrl: url, Retry: testRetryPolicy("/retried")}
+	_, err = client.DoRequest("/retried", nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(keys()))
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	url, keys := startFlakyServer(t, 10, http.StatusBadGateway, "")
+	client := &ComponentClient{RootUrl: url, Retry: testRetryPolicy("/retried")}
+	_, err := client.DoRequest("/retried", nil)
+	assert.NotNil(t, err)
+	if err != nil {
+		assert.Equal(t, "expected status code 200, but got 502. Response body: unavailable", err.Error())
+	}
+	assert.Equal(t, 4, len(keys()))
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	url, keys := startFlakyServer(t, 1, http.StatusTooManyRequests, "1")
+	policy := testRetryPolicy("/retried")
+	policy.MaxRetryAfter = 2 * time.Second
+	client := &ComponentClient{RootUrl: url, Retry: policy}
+	start := time.Now()
+	_, err := client.DoRequest("/retried", nil)
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= time.Second)
+	assert.Equal(t, 2, len(keys()))
+}
+
+func TestRetryGivesUpWhenRetryAfterIsTooLong(t *testing.T) {
+	for _, retryAfter := range []string{"86400", time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat)} {
+		url, keys := startFlakyServer(t, 1, http.StatusServiceUnavailable, retryAfter)
+		client := &ComponentClient{RootUrl: url, Retry: testRetryPolicy("/retried")}
+		start := time.Now()
+		_, err := client.DoRequest("/retried", nil)
+		assert.NotNil(t, err)
+		assert.True(t, time.Since(start) < time.Second, retryAfter)
+		assert.Equal(t, 1, len(keys()))
+	}
+}
+
+func TestRetryAfterIsBoundedByMaxBackoff(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 4, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second}
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"5"}}}
+	wait, ok := policy.wait(1, resp)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, wait)
+
+	resp.Header.Set("Retry-After", "6")
+	_, ok = policy.wait(1, resp)
+	assert.False(t, ok)
+
+	policy.MaxRetryAfter = time.Minute
+	wait, ok = policy.wait(1, resp)
+	assert.True(t, ok)
+	assert.Equal(t, 6*time.Second, wait)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, true},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tc := range testCases {
+		wait, ok := parseRetryAfter(tc.value)
+		assert.Equal(t, tc.ok, ok, tc.value)
+		assert.Equal(t, tc.expected, wait, tc.value)
+	}
+}
+
+func TestRetryBackoffIsBounded(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 10, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt := 1; attempt < 10; attempt++ {
+		wait, ok := policy.wait(attempt, nil)
+		assert.True(t, ok)
+		assert.True(t, wait >= 50*time.Millisecond && wait <= time.Second)
+	}
+}
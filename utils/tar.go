@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// ArchiveFormat is a format supported by PackDirectory and Unpack.
+type ArchiveFormat string
+
+const (
+	ArchiveZip    ArchiveFormat = "zip"
+	ArchiveTar    ArchiveFormat = "tar"
+	ArchiveTarGz  ArchiveFormat = "tar.gz"
+	ArchiveTarZst ArchiveFormat = "tar.zst"
+)
+
+var (
+	zipMagic      = []byte("PK\x03\x04")
+	emptyZipMagic = []byte("PK\x05\x06")
+	gzipMagic     = []byte{0x1f, 0x8b}
+	zstdMagic     = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	tarMagic      = []byte("ustar")
+)
+
+const tarMagicOffset = 257
+
+// DetectArchiveFormat tells the format of the archive by its magic bytes, file extensions are not trusted.
+// Compressed data is assumed to contain a tar archive, which is checked when it is unpacked.
+func DetectArchiveFormat(data []byte) (ArchiveFormat, error) {
+	switch {
+	case bytes.HasPrefix(data, zipMagic), bytes.HasPrefix(data, emptyZipMagic):
+		return ArchiveZip, nil
+	case bytes.HasPrefix(data, gzipMagic):
+		return ArchiveTarGz, nil
+	case bytes.HasPrefix(data, zstdMagic):
+		return ArchiveTarZst, nil
+	case len(data) >= tarMagicOffset+len(tarMagic) && bytes.Equal(data[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic):
+		return ArchiveTar, nil
+	}
+	return "", fmt.Errorf("unsupported archive format, expected zip, tar, tar.gz or tar.zst")
+}
+
+// Unpack extracts an archive of any supported format like Unzip and returns the destination directory. The same
+// limits apply to all formats. Since tar archives have no central directory, their entries are checked while they are
+// read, and the compression ratio applies to the whole archive instead of each file.
+func Unpack(ctx context.Context, data []byte, options UnzipOptions) (string, error) {
+	format, err := DetectArchiveFormat(data)
+	if err != nil {
+		return "", err
+	}
+	if format == ArchiveZip {
+		return Unzip(ctx, data, options)
+	}
+	return UnpackFrom(ctx, bytes.NewReader(data), options)
+}
+
+// UnpackFrom extracts an archive read from the stream like Unpack, without holding it in memory. Tar archives are
+// extracted while they are read. Zip archives can only be read with random access, so they are buffered in a
+// temporary file first, limited by MaxArchiveBytes.
+func UnpackFrom(ctx context.Context, r io.Reader, options UnzipOptions) (string, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(tarMagicOffset + len(tarMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read archive: %v", err)
+	}
+	format, err := DetectArchiveFormat(magic)
+	if err != nil {
+		return "", err
+	}
+	stream := &archiveSizeLimiter{r: contextReader{ctx, buffered}, limit: options.MaxArchiveBytes}
+	if format == ArchiveZip {
+		return unzipStream(ctx, stream, options)
+	}
+	dest, cleanup, err := prepareDestination(options)
+	if err != nil {
+		return "", err
+	}
+	if err := extractTar(ctx, stream, format, dest, options); err != nil {
+		cleanup()
+		if options.Destination == "" {
+			dest = ""
+		}
+		return dest, err
+	}
+	return dest, nil
+}
+
+func unzipStream(ctx context.Context, stream io.Reader, options UnzipOptions) (string, error) {
+	tempFile, err := os.CreateTemp("", "archive-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		Close(tempFile)
+		_ = os.Remove(tempFile.Name())
+	}()
+	size, err := io.Copy(tempFile, stream)
+	if err != nil {
+		return "", err
+	}
+	zipReader, err := openZipReader(tempFile, size, options)
+	if err != nil {
+		return "", err
+	}
+	return unzipReader(ctx, zipReader, options)
+}
+
+func extractTar(ctx context.Context, stream *archiveSizeLimiter, format ArchiveFormat, dest string, options UnzipOptions) error {
+	decompressed, closeStream, err := decompress(stream, format)
+	if err != nil {
+		return err
+	}
+	defer closeStream()
+	if options.MaxCompressionRatio > 0 && format != ArchiveTar {
+		decompressed = &ratioLimitedReader{r: decompressed, compressed: stream, ratio: options.MaxCompressionRatio}
+	}
+
+	tarReader := tar.NewReader(decompressed)
+	e := newExtraction(ctx, "tar", dest, options)
+	for files := 1; ; files++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("extraction cancelled: %w", err)
+		}
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar file: %v", err)
+		}
+		if options.MaxFiles > 0 && files > options.MaxFiles {
+			return fmt.Errorf("too many files in tar, max allowed: %d", options.MaxFiles)
+		}
+		if err := extractTarEntry(e, tarReader, header); err != nil {
+			return err
+		}
+	}
+}
+
+func extractTarEntry(e *extraction, tarReader *tar.Reader, header *tar.Header) error {
+	mode := tarEntryMode(header)
+	if err := checkEntry("tar", header.Name, mode, uint64(max(header.Size, 0)), e.options); err != nil {
+		return err
+	}
+	if mode&fs.ModeSymlink != 0 {
+		if e.options.Symlinks != AllowSymlinksWithinDestination {
+			return nil
+		}
+		return e.writeSymlink(header.Name, header.Linkname)
+	}
+	return e.writeEntry(header.Name, mode, tarReader)
+}
+
+// tarEntryMode returns the file mode of the entry. Hard links are reported as irregular files, so that they are
+// rejected like devices, since they could point to any file already extracted.
+func tarEntryMode(header *tar.Header) fs.FileMode {
+	permissions := fs.FileMode(header.Mode).Perm()
+	if header.Mode&04000 != 0 {
+		permissions |= fs.ModeSetuid
+	}
+	if header.Mode&02000 != 0 {
+		permissions |= fs.ModeSetgid
+	}
+	if header.Mode&01000 != 0 {
+		permissions |= fs.ModeSticky
+	}
+	switch header.Typeflag {
+	case tar.TypeReg:
+		return permissions
+	case tar.TypeDir:
+		return permissions | fs.ModeDir
+	case tar.TypeSymlink:
+		return permissions | fs.ModeSymlink
+	default:
+		return permissions | fs.ModeIrregular
+	}
+}
+
+func decompress(r io.Reader, format ArchiveFormat) (io.Reader, func(), error) {
+	switch format {
+	case ArchiveTarGz:
+		reader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read gzip data: %v", err)
+		}
+		return reader, func() { Close(reader) }, nil
+	case ArchiveTarZst:
+		reader, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read zstd data: %v", err)
+		}
+		return reader, reader.Close, nil
+	default:
+		return r, func() {}, nil
+	}
+}
+
+// archiveSizeLimiter counts the bytes read from the archive and fails when the archive exceeds the limit.
+type archiveSizeLimiter struct {
+	r     io.Reader
+	read  int64
+	limit int64
+}
+
+func (al *archiveSizeLimiter) Read(p []byte) (int, error) {
+	n, err := al.r.Read(p)
+	al.read += int64(n)
+	if al.limit > 0 && al.read > al.limit {
+		return 0, fmt.Errorf("archive exceeds the size limit of %d bytes", al.limit)
+	}
+	return n, err
+}
+
+// ratioLimitedReader fails when more data is decompressed than the compression ratio allows for the compressed data
+// read so far.
+type ratioLimitedReader struct {
+	r            io.Reader
+	compressed   *archiveSizeLimiter
+	decompressed int64
+	ratio        float64
+}
+
+func (rr *ratioLimitedReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.decompressed += int64(n)
+	if float64(rr.decompressed) > rr.ratio*float64(rr.compressed.read) {
+		return 0, fmt.Errorf("compression ratio of archive exceeds the limit of %v", rr.ratio)
+	}
+	return n, err
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/ocelot-cloud/shared/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	mode     int64
+	content  string
+}
+
+func createTar(t *testing.T, entries ...tarEntry) []byte {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Mode: entry.mode, Size: int64(len(entry.content)), Format: tar.FormatUSTAR}
+		if entry.typeflag == tar.TypeSymlink || entry.typeflag == tar.TypeLink {
+			header.Linkname = entry.content
+			header.Size = 0
+		}
+		assert.Nil(t, tw.WriteHeader(header))
+		if header.Size > 0 {
+			_, err := tw.Write([]byte(entry.content))
+			assert.Nil(t, err)
+		}
+	}
+	assert.Nil(t, tw.Close())
+	return buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	gw := gzip.NewWriter(buf)
+	_, err := gw.Write(data)
+	assert.Nil(t, err)
+	assert.Nil(t, gw.Close())
+	return buf.Bytes()
+}
+
+func TestPackAndUnpackDirectory(t *testing.T) {
+	src := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(src, "dir"), 0700))
+	assert.Nil(t, os.WriteFile(filepath.Join(src, "dir", "file.txt"), []byte("hello"), 0600))
+	assert.Nil(t, os.WriteFile(filepath.Join(src, "top.txt"), []byte("world"), 0600))
+
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTar, ArchiveTarGz, ArchiveTarZst} {
+		data, err := PackDirectory(src, format)
+		assert.Nil(t, err)
+		detected, err := DetectArchiveFormat(data)
+		assert.Nil(t, err)
+		assert.Equal(t, format, detected)
+
+		dest, err := Unpack(context.Background(), data, DefaultUnzipOptions())
+		assert.Nil(t, err)
+		content, err := os.ReadFile(filepath.Join(dest, "dir", "file.txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, "hello", string(content))
+		content, err = os.ReadFile(filepath.Join(dest, "top.txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, "world", string(content))
+		RemoveDir(dest)
+	}
+}
+
+func TestDetectArchiveFormatRejectsUnknownData(t *testing.T) {
+	_, err := DetectArchiveFormat([]byte("not an archive"))
+	assert.NotNil(t, err)
+	assert.Equal(t, "unsupported archive format, expected zip, tar, tar.gz or tar.zst", err.Error())
+}
+
+func TestUnpackRejectsTarViolatingOptions(t *testing.T) {
+	large := string(bytes.Repeat([]byte("A"), 100000))
+	testCases := []struct {
+		name          string
+		archive       []byte
+		options       UnzipOptions
+		expectedError string
+	}{
+		{"file count", createTar(t, tarEntry{"a.txt", tar.TypeReg, 0644, "a"}, tarEntry{"b.txt", tar.TypeReg, 0644, "b"}), UnzipOptions{MaxFiles: 1}, "too many files in tar, max allowed: 1"},
+		{"file size", createTar(t, tarEntry{"a.txt", tar.TypeReg, 0644, "hello"}), UnzipOptions{MaxFileBytes: 4}, "file a.txt in tar exceeds the size limit of 4 bytes"},
+		{"total size", createTar(t, tarEntry{"a.txt", tar.TypeReg, 0644, "hello"}, tarEntry{"b.txt", tar.TypeReg, 0644, "world"}), UnzipOptions{MaxTotalBytes: 8}, "unpacked data exceeds limit"},
+		{"compression ratio", gzipBytes(t, createTar(t, tarEntry{"a.txt", tar.TypeReg, 0644, large})), UnzipOptions{MaxCompressionRatio: 10}, "compression ratio of archive exceeds the limit of 10"},
+		{"absolute path", createTar(t, tarEntry{"/etc/cron.d/job", tar.TypeReg, 0644, "evil"}), DefaultUnzipOptions(), "invalid file path in tar: /etc/cron.d/job"},
+		{"cleaned traversal", gzipBytes(t, createTar(t, tarEntry{"a/../../evil.txt", tar.TypeReg, 0644, "evil"})), DefaultUnzipOptions(), "invalid file path in tar: a/../../evil.txt"},
+		{"hard link", createTar(t, tarEntry{"passwd", tar.TypeLink, 0644, "/etc/passwd"}), DefaultUnzipOptions(), "file mode ?rw-r--r-- of passwd is not allowed in tar"},
+		{"device", createTar(t, tarEntry{"null", tar.TypeChar, 0666, ""}), DefaultUnzipOptions(), "file mode ?rw-rw-rw- of null is not allowed in tar"},
+		{"symlink", createTar(t, tarEntry{"link", tar.TypeSymlink, 0777, "/etc/passwd"}), DefaultUnzipOptions(), "symlinks are not allowed in tar: link"},
+		{"symlink outside", createTar(t, tarEntry{"link", tar.TypeSymlink, 0777, "../../etc/passwd"}), UnzipOptions{Symlinks: AllowSymlinksWithinDestination}, "symlink link in tar points outside of the destination: ../../etc/passwd"},
+		{"write through symlink", createTar(t, tarEntry{"dir", tar.TypeSymlink, 0777, "."}, tarEntry{"dir/file.txt", tar.TypeReg, 0644, "hello"}), UnzipOptions{Symlinks: AllowSymlinksWithinDestination}, "file path dir/file.txt in tar traverses a symlink"},
+	}
+
+	for _, tc := range testCases {
+		dir, err := Unpack(context.Background(), tc.archive, tc.options)
+		assert.NotNil(t, err, tc.name)
+		if err != nil {
+			assert.Equal(t, tc.expectedError, err.Error())
+		}
+		assert.Equal(t, "", dir)
+	}
+}
+
+func TestUnpackStripsDangerousModeBitsFromTar(t *testing.T) {
+	dest := t.TempDir()
+	_, err := Unpack(context.Background(), createTar(t, tarEntry{"a.sh", tar.TypeReg, 04777, "#!/bin/sh"}), UnzipOptions{Destination: dest})
+	assert.Nil(t, err)
+	info, err := os.Stat(filepath.Join(dest, "a.sh"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0), info.Mode()&(os.ModeSetuid|0002))
+}
@@ -1,16 +1,15 @@
 package utils
 
 import (
-	"archive/zip"
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/tls"
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -26,6 +25,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -37,6 +37,7 @@ const (
 	HostField             = "host"
 	CurrentAttemptField   = "current_attempt"
 	MaximumAttemptsFields = "maximum_attempts"
+	PathField             = "path"
 )
 
 var Logger = deepstack.NewDeepStackLogger(os.Getenv("LOG_LEVEL"), true)
@@ -58,16 +59,51 @@ func SendJsonResponse(w http.ResponseWriter, data interface{}) {
 	}
 }
 
+// DefaultRequestTimeout applies to requests of a ComponentClient without Timeout.
+const DefaultRequestTimeout = time.Minute
+
 type ComponentClient struct {
 	Cookie            *http.Cookie
 	SetCookieHeader   bool
 	RootUrl           string
 	Origin            string
 	VerifyCertificate bool
+	// Timeout limits each attempt of a request including reading the response body. DefaultRequestTimeout applies if
+	// it is zero, a negative value disables the timeout. Shorter timeouts for single calls can be set with the
+	// deadline of the context passed to DoRequestContext.
+	Timeout time.Duration
+	// HTTPClient sends the requests if set, VerifyCertificate, Transport and the pool settings are then ignored.
+	HTTPClient *http.Client
+	// Transport is used by the client built by ComponentClient if set, instead of a transport with the pool settings.
+	Transport http.RoundTripper
+	// MaxIdleConnsPerHost is the number of connections kept open for reuse, DefaultMaxIdleConnsPerHost applies if it
+	// is zero.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes connections which were not used for the duration, DefaultIdleConnTimeout applies if it
+	// is zero.
+	IdleConnTimeout time.Duration
+	// Retry retries failed requests to the paths of the policy, requests are sent once if it is nil.
+	Retry *RetryPolicy
+
+	// mutex guards the lazily built client and the cookie, so that the client can be used by many goroutines. The
+	// settings must not be changed after the first request, since the client is only built once.
+	mutex  sync.Mutex
+	client *http.Client
 }
 
+const (
+	DefaultMaxIdleConnsPerHost = 32
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
 func (c *ComponentClient) DoRequest(path string, payload interface{}) ([]byte, error) {
-	resp, err := c.DoRequestWithFullResponse(path, payload)
+	return c.DoRequestContext(context.Background(), path, payload)
+}
+
+// DoRequestContext sends the request like DoRequest and stops when the context is done. Errors caused by the
+// context or the timeout wrap context.Canceled or context.DeadlineExceeded.
+func (c *ComponentClient) DoRequestContext(ctx context.Context, path string, payload interface{}) ([]byte, error) {
+	resp, err := c.DoRequestWithFullResponseContext(ctx, path, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -81,31 +117,45 @@ func (c *ComponentClient) DoRequest(path string, payload interface{}) ([]byte, e
 }
 
 func (c *ComponentClient) DoRequestWithFullResponse(path string, payload interface{}) (*http.Response, error) {
-	url := c.RootUrl + path
+	return c.DoRequestWithFullResponseContext(context.Background(), path, payload)
+}
 
+// DoRequestWithFullResponseContext sends the request like DoRequestWithFullResponse and stops when the context is
+// done, see DoRequestContext.
+func (c *ComponentClient) DoRequestWithFullResponseContext(ctx context.Context, path string, payload interface{}) (*http.Response, error) {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal payload: %v", err)
 	}
-	payloadReader := bytes.NewReader(payloadBytes)
-	req, err := http.NewRequest("POST", url, payloadReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	SetCookieHeaders(req, c)
-	if c.Origin != "" {
-		req.Header.Set("Origin", c.Origin)
+
+	retried := c.Retry.appliesTo(path)
+	idempotencyKey := ""
+	if retried {
+		if idempotencyKey, err = generateIdempotencyKey(); err != nil {
+			return nil, fmt.Errorf("failed to generate idempotency key: %v", err)
+		}
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: !c.VerifyCertificate}, // #nosec G402 (CWE-295): TLS InsecureSkipVerify may be true; tolerated by design
-		},
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		resp, err = c.sendRequest(ctx, path, payloadBytes, idempotencyKey)
+		if !retried || !c.Retry.shouldRetry(ctx, attempt, resp, err) {
+			break
+		}
+		wait, ok := c.Retry.wait(attempt, resp)
+		if !ok {
+			Logger.Info("not retrying request, the server asks to wait too long", PathField, path, CurrentAttemptField, attempt)
+			break
+		}
+		Logger.Info("retrying request", PathField, path, CurrentAttemptField, attempt, MaximumAttemptsFields, c.Retry.MaxAttempts)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to send request: %w", ctx.Err())
+		case <-time.After(wait):
+		}
 	}
-	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
+		return nil, err
 	}
 
 	respBody, err := assertOkStatusAndExtractBody(resp)
@@ -114,7 +164,7 @@ func (c *ComponentClient) DoRequestWithFullResponse(path string, payload interfa
 	}
 
 	if len(resp.Cookies()) == 1 {
-		c.Cookie = resp.Cookies()[0]
+		c.SetCookie(resp.Cookies()[0])
 	}
 
 	// Response body can only be read once. When reading it a second time, an error occurs. So a copy is created.
@@ -126,6 +176,96 @@ func (c *ComponentClient) DoRequestWithFullResponse(path string, payload interfa
 	return newResp, nil
 }
 
+// sendRequest sends a single attempt of the request and reads the response body within the timeout of the client.
+// The returned response holds the body in memory.
+func (c *ComponentClient) sendRequest(ctx context.Context, path string, payloadBytes []byte, idempotencyKey string) (*http.Response, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.RootUrl+path, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.mutex.Lock()
+	SetCookieHeaders(req, c)
+	c.mutex.Unlock()
+	if c.Origin != "" {
+		req.Header.Set("Origin", c.Origin)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer Close(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// SetCookie sets the cookie sent with the requests, it is safe to call while requests are running.
+func (c *ComponentClient) SetCookie(cookie *http.Cookie) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Cookie = cookie
+}
+
+// CloseIdleConnections closes the connections kept open for reuse, for example when shutting down.
+func (c *ComponentClient) CloseIdleConnections() {
+	c.httpClient().CloseIdleConnections()
+}
+
+// httpClient returns the injected client or builds the client shared by all requests on first use, so that
+// connections and TLS sessions are reused.
+func (c *ComponentClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client == nil {
+		transport := c.Transport
+		if transport == nil {
+			transport = c.newTransport()
+		}
+		c.client = &http.Client{Transport: transport}
+	}
+	return c.client
+}
+
+func (c *ComponentClient) newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: !c.VerifyCertificate} // #nosec G402 (CWE-295): TLS InsecureSkipVerify may be true; tolerated by design
+	transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	if c.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	}
+	transport.MaxIdleConns = max(transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	transport.IdleConnTimeout = DefaultIdleConnTimeout
+	if c.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = c.IdleConnTimeout
+	}
+	return transport
+}
+
+func (c *ComponentClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	switch {
+	case c.Timeout < 0:
+		return ctx, func() {}
+	case c.Timeout == 0:
+		return context.WithTimeout(ctx, DefaultRequestTimeout)
+	default:
+		return context.WithTimeout(ctx, c.Timeout)
+	}
+}
+
 func SetCookieHeaders(req *http.Request, c *ComponentClient) {
 	if c.SetCookieHeader && c.Cookie != nil {
 		req.AddCookie(c.Cookie)
@@ -143,14 +283,12 @@ func assertOkStatusAndExtractBody(resp *http.Response) ([]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("expected status code %d, but got %d. Also failed to read response body: %v", resp.StatusCode, resp.StatusCode, err)
 		}
-		errorMessage := GetErrMsg(resp.StatusCode, string(respBody))
-		trimmedStr := strings.TrimSuffix(errorMessage, "\n")
-		return nil, errors.New(trimmedStr)
+		return nil, newHTTPError(resp, respBody)
 	}
 
 	respBody, err := io.ReadAll(teeReader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	return respBody, nil
@@ -224,72 +362,6 @@ func Hash(clearText string) (string, error) {
 	return hex.EncodeToString(hashValue.Sum(nil)), nil
 }
 
-func ZipDirectoryToBytes(dirPath string) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	zipWriter := zip.NewWriter(buf)
-
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if path == dirPath {
-			return nil
-		}
-
-		relPath, err := filepath.Rel(dirPath, path)
-		if err != nil {
-			return err
-		}
-
-		header, err := zip.FileInfoHeader(info)
-		if err != nil {
-			return err
-		}
-
-		header.Name = relPath
-
-		if info.IsDir() {
-			header.Name += "/"
-		} else {
-			header.Method = zip.Deflate
-		}
-
-		writer, err := zipWriter.CreateHeader(header)
-		if err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			file, err := os.Open(path) // #nosec G304 (CWE-22): Potential file inclusion via variable; but required by design
-			if err != nil {
-				return err
-			}
-			defer Close(file)
-
-			_, err = io.Copy(writer, file)
-			if err != nil {
-				return err
-			}
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		Close(zipWriter)
-		return nil, err
-	}
-
-	err = zipWriter.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	Logger.Info("zipped files in directory with size", DirectoryField, dirPath, SizeInBytesField, len(buf.Bytes()))
-	return buf.Bytes(), nil
-}
-
 func UnpackResponse[T any](object interface{}) (*T, error) {
 	respBody, ok := object.([]byte)
 	if !ok {
@@ -304,96 +376,6 @@ func UnpackResponse[T any](object interface{}) (*T, error) {
 	return &result, nil
 }
 
-var (
-	maxNumberOfFilesAllowed            = 100
-	maximumAllowedUnpackedBytesFromZip = int64(10 * 1024 * 1024) // 10 MB
-)
-
-// UnzipToTempDir unzips the given zip bytes to a temporary directory and returns the path to the directory.
-func UnzipToTempDir(zipBytes []byte) (string, error) {
-	tempDir, err := createTempDir()
-	if err != nil {
-		return "", err
-	}
-	return tempDir, unzipToDir(zipBytes, tempDir, maxNumberOfFilesAllowed, maximumAllowedUnpackedBytesFromZip)
-}
-
-func createTempDir() (string, error) {
-	tempDir, err := os.MkdirTemp("", "temp")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp dir: %v", err)
-	}
-	return tempDir, nil
-}
-
-func unzipToDir(zipBytes []byte, dest string, maxNumberOfFilesAllowed int, maxUnpackedBytesFromZip int64) error {
-	zipReader, err := zip.NewReader(bytes.NewReader(zipBytes), int64(len(zipBytes)))
-	if err != nil {
-		return fmt.Errorf("failed to read zip file: %v", err)
-	}
-
-	var totalUnpacked int64
-	if len(zipReader.File) > maxNumberOfFilesAllowed {
-		return fmt.Errorf("too many files in zip: %d, max allowed: %d", len(zipReader.File), maxNumberOfFilesAllowed)
-	}
-
-	for _, file := range zipReader.File {
-		if strings.Contains(file.Name, "..") {
-			return fmt.Errorf("invalid file path in zip: %s", file.Name)
-		}
-
-		if err := extractFile(file, dest, &totalUnpacked, maxUnpackedBytesFromZip); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func extractFile(file *zip.File, dest string, totalUnpacked *int64, limit int64) error {
-	fpath := filepath.Join(dest, file.Name) // #nosec G305 (CWE-22): File traversal when extracting zip/tar archive; safe due to sanitized internal file paths
-
-	if file.FileInfo().IsDir() {
-		return os.MkdirAll(fpath, 0700)
-	}
-
-	if err := os.MkdirAll(filepath.Dir(fpath), 0700); err != nil {
-		return err
-	}
-
-	rc, err := file.Open()
-	if err != nil {
-		return err
-	}
-	defer Close(rc)
-
-	outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode()) // #nosec G304 (CWE-22): File inclusion via variable path; controlled and expected
-	if err != nil {
-		return err
-	}
-	defer Close(outFile)
-
-	// #nosec G110 (CWE-409): DoS risk via zip bomb mitigated by max unpack limit
-	_, err = io.Copy(outFile, limitedCounter{rc, totalUnpacked, limit})
-	return err
-}
-
-type limitedCounter struct {
-	r     io.Reader
-	total *int64
-	limit int64
-}
-
-func (lc limitedCounter) Read(p []byte) (int, error) {
-	n, err := lc.r.Read(p)
-	if n > 0 {
-		if *lc.total+int64(n) > lc.limit {
-			return 0, fmt.Errorf("unpacked data exceeds limit")
-		}
-		*lc.total += int64(n)
-	}
-	return n, err
-}
-
 func ExecuteShellCommand(shellCommand string) error {
 	return exec.Command("/bin/sh", "-c", shellCommand).Run()
 }
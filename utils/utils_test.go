@@ -3,10 +3,18 @@ package utils
 import (
 	"archive/zip"
 	"bytes"
+	"context"
+	"errors"
 	"github.com/ocelot-cloud/shared/assert"
+	"io"
+	"io/fs"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -83,10 +91,10 @@ func TestUnzipToDirLimit(t *testing.T) {
 	assert.Nil(t, err)
 	Close(zw)
 
-	err = unzipToDir(buf.Bytes(), tempDir, 1, 101)
+	_, err = Unzip(context.Background(), buf.Bytes(), UnzipOptions{Destination: tempDir, MaxFiles: 1, MaxTotalBytes: 101})
 	assert.Nil(t, err)
 
-	err = unzipToDir(buf.Bytes(), tempDir, 1, 99)
+	_, err = Unzip(context.Background(), buf.Bytes(), UnzipOptions{Destination: tempDir, MaxFiles: 1, MaxTotalBytes: 99})
 	assert.NotNil(t, err)
 	assert.Equal(t, "unpacked data exceeds limit", err.Error())
 }
@@ -105,14 +113,49 @@ func TestUnzipToDirFileCountLimit(t *testing.T) {
 	assert.Nil(t, err)
 	Close(zw)
 
-	err = unzipToDir(buf.Bytes(), tempDir, 2, 99)
+	_, err = Unzip(context.Background(), buf.Bytes(), UnzipOptions{Destination: tempDir, MaxFiles: 2, MaxTotalBytes: 99})
 	assert.Nil(t, err)
 
-	err = unzipToDir(buf.Bytes(), tempDir, 1, 99)
+	_, err = Unzip(context.Background(), buf.Bytes(), UnzipOptions{Destination: tempDir, MaxFiles: 1, MaxTotalBytes: 99})
 	assert.NotNil(t, err)
 	assert.Equal(t, "too many files in zip: 2, max allowed: 1", err.Error())
 }
 
+func TestOpenZip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	f, _ := zw.Create("file.txt")
+	_, err := f.Write(bytes.Repeat([]byte("A"), 100))
+	assert.Nil(t, err)
+	Close(zw)
+
+	fsys, err := OpenZipWithOptions(buf.Bytes(), UnzipOptions{MaxFiles: 1, MaxTotalBytes: 100})
+	assert.Nil(t, err)
+	content, err := fs.ReadFile(fsys, "file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, 100, len(content))
+
+	_, err = OpenZipWithOptions(buf.Bytes(), UnzipOptions{MaxFiles: 1, MaxTotalBytes: 99})
+	assert.NotNil(t, err)
+	assert.Equal(t, "unpacked data exceeds limit", err.Error())
+
+	_, err = OpenZip([]byte("hello"))
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to read zip file: zip: not a valid zip file", err.Error())
+}
+
+func TestOpenZipRejectsPathTraversal(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	_, err := zw.Create("../file.txt")
+	assert.Nil(t, err)
+	Close(zw)
+
+	_, err = OpenZip(buf.Bytes())
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid file path in zip: ../file.txt", err.Error())
+}
+
 func TestExtractFromLine(t *testing.T) {
 	assert.Equal(t, 0, len(extractTagsFromLine("// random comment")))
 
@@ -126,3 +169,96 @@ func TestExtractFromLine(t *testing.T) {
 	assert.Equal(t, "a", tags[0])
 	assert.Equal(t, "c", tags[1])
 }
+
+func startSlowServer(t *testing.T, delay time.Duration) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the request context is only cancelled on disconnects once the body was read
+		_, _ = io.Copy(io.Discard, r.Body)
+		select {
+		case <-time.After(delay):
+			SendJsonResponse(w, "done")
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestComponentClientTimeout(t *testing.T) {
+	client := &ComponentClient{RootUrl: startSlowServer(t, time.Second), Timeout: 50 * time.Millisecond}
+	_, err := client.DoRequest("/slow", nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	client.Timeout = -1
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.DoRequestContext(ctx, "/slow", nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestComponentClientCancellation(t *testing.T) {
+	client := &ComponentClient{RootUrl: startSlowServer(t, time.Second)}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err := client.DoRequestContext(ctx, "/slow", nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	response, err := (&ComponentClient{RootUrl: startSlowServer(t, 0)}).DoRequestContext(context.Background(), "/fast", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "\"done\"", string(response))
+}
+
+func TestComponentClientReusesConnections(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SendJsonResponse(w, "pong")
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := &ComponentClient{RootUrl: server.URL, MaxIdleConnsPerHost: 4}
+	for round := 0; round < 5; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := client.DoRequest("/ping", nil)
+				assert.Nil(t, err)
+			}()
+		}
+		wg.Wait()
+	}
+	assert.True(t, connections.Load() <= 4)
+	client.CloseIdleConnections()
+}
+
+type countingRoundTripper struct {
+	requests atomic.Int32
+}
+
+func (rt *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestComponentClientUsesInjectedTransport(t *testing.T) {
+	url := startSlowServer(t, 0)
+	transport := &countingRoundTripper{}
+	_, err := (&ComponentClient{RootUrl: url, Transport: transport}).DoRequest("/ping", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), transport.requests.Load())
+
+	httpClient := &http.Client{Transport: transport}
+	_, err = (&ComponentClient{RootUrl: url, HTTPClient: httpClient}).DoRequest("/ping", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), transport.requests.Load())
+}
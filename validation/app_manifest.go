@@ -0,0 +1,413 @@
+package validation
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	notAllowedKeyInAppYaml         = "not allowed key in app.yml: %s"
+	invalidUrlPathInAppYaml        = "invalid url_path in app.yml: %s"
+	invalidPortInAppYaml           = "invalid port in app.yml: %s"
+	wrongTypeInAppYaml             = "'%s' in app.yml must be %s"
+	descriptionTooLong             = "description in app.yml must not be longer than %d characters"
+	invalidHomepageInAppYaml       = "invalid homepage in app.yml, it must be an http or https URL: %s"
+	invalidLicenseInAppYaml        = "invalid license in app.yml, it must be an SPDX license identifier: %s"
+	invalidIconInAppYaml           = "invalid icon in app.yml, it must be the name of a png, jpg, svg or webp file in the zip: %s"
+	invalidCategoryInAppYaml       = "invalid category in app.yml, it must consist of lowercase letters, digits and dashes: %s"
+	tooManyCategoriesInAppYaml     = "too many categories in app.yml, at most %d are allowed"
+	duplicateCategoryInAppYaml     = "duplicate category in app.yml: %s"
+	invalidRequiredEnvInAppYaml    = "invalid name of required environment variable in app.yml: %s"
+	missingEnvDescriptionInAppYaml = "required environment variable '%s' in app.yml needs a description"
+	duplicateRequiredEnvInAppYaml  = "duplicate required environment variable in app.yml: %s"
+	invalidMinVersionInAppYaml     = "invalid min_ocelot_cloud_version in app.yml, it must look like '1.2.3': %s"
+	invalidCapabilityInAppYaml     = "invalid capability in app.yml, it must look like 'CAP_NET_RAW': %s"
+	missingCapabilityJustification = "capability '%s' in app.yml needs a justification"
+	duplicateCapabilityInAppYaml   = "duplicate capability in app.yml: %s"
+	iconFileMissing                = "icon file declared in app.yml is missing in zip: %s"
+	iconFileTooLarge               = "icon file %s must not be larger than %d bytes"
+	invalidIconFile                = "icon file %s is not a valid %s image"
+	invalidSvgIconFile             = "icon file %s is not a valid svg image: %v"
+)
+
+const (
+	maxDescriptionLength = 1000
+	maxCategories        = 10
+	maxIconFileSize      = 512 * 1024
+)
+
+var (
+	licenseRegex         = regexp.MustCompile(`^[A-Za-z0-9.+-]{1,64}( (AND|OR|WITH) [A-Za-z0-9.+-]{1,64})*$`)
+	iconFileNameRegex    = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}\.(png|jpg|jpeg|svg|webp)$`)
+	categoryRegex        = regexp.MustCompile(`^[a-z0-9-]{1,30}$`)
+	environmentNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,127}$`)
+	versionRegex         = regexp.MustCompile(`^v?[0-9]{1,5}\.[0-9]{1,5}(\.[0-9]{1,5})?$`)
+	capabilityRegex      = regexp.MustCompile(`^CAP_[A-Z_]{1,30}$`)
+)
+
+// AppManifest is the content of the optional app.yml of an app version. All fields are optional.
+type AppManifest struct {
+	UrlPath               string                        `yaml:"url_path,omitempty"`
+	Port                  int                           `yaml:"port,omitempty"`
+	Description           string                        `yaml:"description,omitempty"`
+	Homepage              string                        `yaml:"homepage,omitempty"`
+	License               string                        `yaml:"license,omitempty"`
+	Icon                  string                        `yaml:"icon,omitempty"`
+	Categories            []string                      `yaml:"categories,omitempty"`
+	RequiredEnvironment   []RequiredEnvironmentVariable `yaml:"required_environment,omitempty"`
+	MinOcelotCloudVersion string                        `yaml:"min_ocelot_cloud_version,omitempty"`
+	Capabilities          []CapabilityRequest           `yaml:"capabilities,omitempty"`
+	Secrets               []SecretDeclaration           `yaml:"secrets,omitempty"`
+	// ReadinessPath is requested over HTTP on the port of the main service to tell whether the app is ready. The
+	// completion step turns it into a healthcheck, unless the main service declares its own.
+	ReadinessPath string `yaml:"readiness_path,omitempty"`
+}
+
+// RequiredEnvironmentVariable is a variable the user has to provide when installing the app.
+type RequiredEnvironmentVariable struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+// CapabilityRequest is a linux capability the app needs in addition to the ones granted by the hardening profile.
+// The justification is shown to operators reviewing the app.
+type CapabilityRequest struct {
+	Name          string `yaml:"name"`
+	Justification string `yaml:"justification"`
+}
+
+// LoadAppManifest reads and validates the app.yml at the given path.
+func LoadAppManifest(filePath string) (*AppManifest, error) {
+	data, err := os.ReadFile(filePath) // #nosec G304 (CWE-22): Potential file inclusion via variable; the caller decides which file to read
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app.yml: %v", err)
+	}
+	return ParseAppManifest(data)
+}
+
+// ParseAppManifest decodes and validates the content of an app.yml. Unknown keys and values of the wrong type are rejected.
+func ParseAppManifest(data []byte) (*AppManifest, error) {
+	r := &report{}
+	manifest := parseAppManifest(r, data)
+	if err := findingsToError(r.findings); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// parseAppManifest reports all problems of the app.yml and returns the valid part of the manifest, or nil if the
+// file could not be parsed at all.
+func parseAppManifest(r *report, data []byte) *AppManifest {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		r.addErrorAt(RuleAppYaml, appYamlFileName, yamlErrorLine(err), fmt.Errorf("failed to parse app.yml: %v", err))
+		return nil
+	}
+	manifest := &AppManifest{}
+	if len(root.Content) == 0 || root.Content[0].ShortTag() == "!!null" {
+		return manifest
+	}
+	appConfig := root.Content[0]
+	if appConfig.Kind != yaml.MappingNode {
+		r.addErrorAt(RuleAppYaml, appYamlFileName, appConfig.Line, fmt.Errorf("failed to parse app.yml: line %d: app.yml must be a map", appConfig.Line))
+		return nil
+	}
+
+	for i := 0; i+1 < len(appConfig.Content); i += 2 {
+		keyNode, valueNode := appConfig.Content[i], appConfig.Content[i+1]
+		var err error
+		if decode, ok := appManifestFields[keyNode.Value]; ok {
+			err = decode(manifest, valueNode)
+		} else {
+			err = fmt.Errorf(notAllowedKeyInAppYaml, keyNode.Value)
+		}
+		if err != nil {
+			r.add(Finding{RuleID: RuleAppYaml, Severity: SeverityError, File: appYamlFileName, Line: keyNode.Line, Column: keyNode.Column, Message: err.Error()})
+		}
+	}
+	return manifest
+}
+
+// appManifestFields decodes and validates the value of each allowed key of the app.yml into the manifest.
+var appManifestFields = map[string]func(manifest *AppManifest, node *yaml.Node) error{
+	"url_path": func(manifest *AppManifest, node *yaml.Node) error {
+		if node.Kind == yaml.ScalarNode && !IsValidURLPath(node.Value) {
+			return fmt.Errorf(invalidUrlPathInAppYaml, node.Value)
+		}
+		return decodeManifestString(node, "url_path", &manifest.UrlPath)
+	},
+	"readiness_path": func(manifest *AppManifest, node *yaml.Node) error {
+		if node.Kind == yaml.ScalarNode && !readinessPathRegex.MatchString(node.Value) {
+			return fmt.Errorf(invalidReadinessPathInAppYaml, node.Value)
+		}
+		return decodeManifestString(node, "readiness_path", &manifest.ReadinessPath)
+	},
+	"port": func(manifest *AppManifest, node *yaml.Node) error {
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!int" {
+			return fmt.Errorf(wrongTypeInAppYaml, "port", "a number")
+		}
+		var port int
+		if err := node.Decode(&port); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf(invalidPortInAppYaml, node.Value)
+		}
+		manifest.Port = port
+		return nil
+	},
+	"description": func(manifest *AppManifest, node *yaml.Node) error {
+		if err := decodeManifestString(node, "description", &manifest.Description); err != nil {
+			return err
+		}
+		if len(manifest.Description) > maxDescriptionLength {
+			manifest.Description = ""
+			return fmt.Errorf(descriptionTooLong, maxDescriptionLength)
+		}
+		return nil
+	},
+	"homepage": func(manifest *AppManifest, node *yaml.Node) error {
+		var homepage string
+		if err := decodeManifestString(node, "homepage", &homepage); err != nil {
+			return err
+		}
+		parsed, err := url.Parse(homepage)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf(invalidHomepageInAppYaml, homepage)
+		}
+		manifest.Homepage = homepage
+		return nil
+	},
+	"license": func(manifest *AppManifest, node *yaml.Node) error {
+		var license string
+		if err := decodeManifestString(node, "license", &license); err != nil {
+			return err
+		}
+		if !licenseRegex.MatchString(license) {
+			return fmt.Errorf(invalidLicenseInAppYaml, license)
+		}
+		manifest.License = license
+		return nil
+	},
+	"icon": func(manifest *AppManifest, node *yaml.Node) error {
+		var icon string
+		if err := decodeManifestString(node, "icon", &icon); err != nil {
+			return err
+		}
+		if !iconFileNameRegex.MatchString(icon) {
+			return fmt.Errorf(invalidIconInAppYaml, icon)
+		}
+		manifest.Icon = icon
+		return nil
+	},
+	"categories": func(manifest *AppManifest, node *yaml.Node) error {
+		var categories []string
+		if err := decodeManifestStringList(node, "categories", &categories); err != nil {
+			return err
+		}
+		if len(categories) > maxCategories {
+			return fmt.Errorf(tooManyCategoriesInAppYaml, maxCategories)
+		}
+		seen := make(map[string]bool)
+		for _, category := range categories {
+			if !categoryRegex.MatchString(category) {
+				return fmt.Errorf(invalidCategoryInAppYaml, category)
+			}
+			if seen[category] {
+				return fmt.Errorf(duplicateCategoryInAppYaml, category)
+			}
+			seen[category] = true
+		}
+		manifest.Categories = categories
+		return nil
+	},
+	"required_environment": func(manifest *AppManifest, node *yaml.Node) error {
+		if node.Kind != yaml.SequenceNode {
+			return fmt.Errorf(wrongTypeInAppYaml, "required_environment", "a list")
+		}
+		var variables []RequiredEnvironmentVariable
+		seen := make(map[string]bool)
+		for _, item := range node.Content {
+			variable, err := decodeRequiredEnvironmentVariable(item)
+			if err != nil {
+				return err
+			}
+			if seen[variable.Name] {
+				return fmt.Errorf(duplicateRequiredEnvInAppYaml, variable.Name)
+			}
+			seen[variable.Name] = true
+			variables = append(variables, variable)
+		}
+		manifest.RequiredEnvironment = variables
+		return nil
+	},
+	"min_ocelot_cloud_version": func(manifest *AppManifest, node *yaml.Node) error {
+		var version string
+		if err := decodeManifestString(node, "min_ocelot_cloud_version", &version); err != nil {
+			return err
+		}
+		if !versionRegex.MatchString(version) {
+			return fmt.Errorf(invalidMinVersionInAppYaml, version)
+		}
+		manifest.MinOcelotCloudVersion = version
+		return nil
+	},
+	"capabilities": func(manifest *AppManifest, node *yaml.Node) error {
+		if node.Kind != yaml.SequenceNode {
+			return fmt.Errorf(wrongTypeInAppYaml, "capabilities", "a list")
+		}
+		var capabilities []CapabilityRequest
+		seen := make(map[string]bool)
+		for _, item := range node.Content {
+			capability, err := decodeCapabilityRequest(item)
+			if err != nil {
+				return err
+			}
+			if seen[capability.Name] {
+				return fmt.Errorf(duplicateCapabilityInAppYaml, capability.Name)
+			}
+			seen[capability.Name] = true
+			capabilities = append(capabilities, capability)
+		}
+		manifest.Capabilities = capabilities
+		return nil
+	},
+	"secrets": func(manifest *AppManifest, node *yaml.Node) error {
+		if node.Kind != yaml.SequenceNode {
+			return fmt.Errorf(wrongTypeInAppYaml, "secrets", "a list")
+		}
+		var secrets []SecretDeclaration
+		seen := make(map[string]bool)
+		for _, item := range node.Content {
+			secret, err := decodeSecretDeclaration(item)
+			if err != nil {
+				return err
+			}
+			if seen[secret.Name] {
+				return fmt.Errorf(duplicateSecretInAppYaml, secret.Name)
+			}
+			seen[secret.Name] = true
+			secrets = append(secrets, secret)
+		}
+		manifest.Secrets = secrets
+		return nil
+	},
+}
+
+func decodeCapabilityRequest(node *yaml.Node) (CapabilityRequest, error) {
+	capability := CapabilityRequest{}
+	if node.Kind != yaml.MappingNode {
+		return capability, fmt.Errorf(wrongTypeInAppYaml, "capabilities", "a list of maps with 'name' and 'justification'")
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		var err error
+		switch key {
+		case "name":
+			err = decodeManifestString(value, "capabilities.name", &capability.Name)
+		case "justification":
+			err = decodeManifestString(value, "capabilities.justification", &capability.Justification)
+		default:
+			err = fmt.Errorf(notAllowedKeyInAppYaml, "capabilities."+key)
+		}
+		if err != nil {
+			return capability, err
+		}
+	}
+	if !capabilityRegex.MatchString(capability.Name) {
+		return capability, fmt.Errorf(invalidCapabilityInAppYaml, capability.Name)
+	}
+	if strings.TrimSpace(capability.Justification) == "" {
+		return capability, fmt.Errorf(missingCapabilityJustification, capability.Name)
+	}
+	return capability, nil
+}
+
+func decodeRequiredEnvironmentVariable(node *yaml.Node) (RequiredEnvironmentVariable, error) {
+	variable := RequiredEnvironmentVariable{}
+	if node.Kind != yaml.MappingNode {
+		return variable, fmt.Errorf(wrongTypeInAppYaml, "required_environment", "a list of maps with 'name' and 'description'")
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		var err error
+		switch key {
+		case "name":
+			err = decodeManifestString(value, "required_environment.name", &variable.Name)
+		case "description":
+			err = decodeManifestString(value, "required_environment.description", &variable.Description)
+		default:
+			err = fmt.Errorf(notAllowedKeyInAppYaml, "required_environment."+key)
+		}
+		if err != nil {
+			return variable, err
+		}
+	}
+	if !environmentNameRegex.MatchString(variable.Name) {
+		return variable, fmt.Errorf(invalidRequiredEnvInAppYaml, variable.Name)
+	}
+	if strings.TrimSpace(variable.Description) == "" {
+		return variable, fmt.Errorf(missingEnvDescriptionInAppYaml, variable.Name)
+	}
+	return variable, nil
+}
+
+func decodeManifestString(node *yaml.Node, key string, target *string) error {
+	if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!str" {
+		return fmt.Errorf(wrongTypeInAppYaml, key, "a string")
+	}
+	*target = node.Value
+	return nil
+}
+
+func decodeManifestStringList(node *yaml.Node, key string, target *[]string) error {
+	if node.Kind != yaml.SequenceNode {
+		return fmt.Errorf(wrongTypeInAppYaml, key, "a list of strings")
+	}
+	values := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		var value string
+		if err := decodeManifestString(item, key, &value); err != nil {
+			return fmt.Errorf(wrongTypeInAppYaml, key, "a list of strings")
+		}
+		values = append(values, value)
+	}
+	*target = values
+	return nil
+}
+
+// validateIconFile checks that the icon is small and that its content matches its file extension.
+func validateIconFile(r *report, fsys fs.FS, fileName string) {
+	data, err := readVersionFile(fsys, fileName)
+	if err != nil {
+		r.addError(RuleArchive, fileName, fmt.Errorf("failed to read icon file: %v", err))
+		return
+	}
+	if len(data) > maxIconFileSize {
+		r.addError(RuleArchive, fileName, fmt.Errorf(iconFileTooLarge, fileName, maxIconFileSize))
+		return
+	}
+	extension := fileName[strings.LastIndex(fileName, ".")+1:]
+	var valid bool
+	switch extension {
+	case "png":
+		valid = http.DetectContentType(data) == "image/png"
+	case "jpg", "jpeg":
+		extension = "jpeg"
+		valid = http.DetectContentType(data) == "image/jpeg"
+	case "webp":
+		valid = http.DetectContentType(data) == "image/webp"
+	case "svg":
+		if err := validateSvgIcon(data); err != nil {
+			r.addError(RuleArchive, fileName, fmt.Errorf(invalidSvgIconFile, fileName, err))
+			return
+		}
+		valid = true
+	}
+	if !valid {
+		r.addError(RuleArchive, fileName, fmt.Errorf(invalidIconFile, fileName, extension))
+	}
+}
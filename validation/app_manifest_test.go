@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"github.com/ocelot-cloud/shared/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAppManifest(t *testing.T) {
+	manifest, err := LoadAppManifest(getSamplesDir() + "/app-yamls/full-manifest.yml")
+	assert.Nil(t, err)
+	expected := &AppManifest{
+		UrlPath:               "/",
+		Port:                  3000,
+		Description:           "Gitea is a painless self-hosted Git service.",
+		Homepage:              "https://about.gitea.com",
+		License:               "MIT",
+		Icon:                  "icon.png",
+		Categories:            []string{"development", "git"},
+		RequiredEnvironment:   []RequiredEnvironmentVariable{{Name: "ADMIN_EMAIL", Description: "E-mail address of the initial admin user"}},
+		MinOcelotCloudVersion: "1.2.0",
+	}
+	assert.Equal(t, expected, manifest)
+
+	manifest, err = LoadAppManifest(getSamplesDir() + "/app-yamls/empty-is-valid.yml")
+	assert.Nil(t, err)
+	assert.Equal(t, &AppManifest{}, manifest)
+
+	_, err = LoadAppManifest(getSamplesDir() + "/app-yamls/not-existing.yml")
+	assert.NotNil(t, err)
+}
+
+func TestLoadAppManifestSamples(t *testing.T) {
+	testCases := []struct {
+		file          string
+		expectedError string
+	}{
+		{"empty-is-valid", ""},
+		{"full-valid", ""},
+		{"port-out-of-range", "invalid port in app.yml: 123456"},
+		{"not-allowed-field", "not allowed key in app.yml: not_allowed_field"},
+		{"not-a-path", "invalid url_path in app.yml: <script>alert('XSS')</script>"},
+		{"full-manifest", ""},
+		{"port-is-a-string", "'port' in app.yml must be a number"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.file, func(t *testing.T) {
+			_, err := LoadAppManifest(getSamplesDir() + "/app-yamls/" + tc.file + ".yml")
+			if tc.expectedError == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+				assert.Equal(t, tc.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseAppManifestRejectsInvalidFields(t *testing.T) {
+	testCases := []struct {
+		content       string
+		expectedError string
+	}{
+		{"port: 0", "invalid port in app.yml: 0"},
+		{"port: [80]", "'port' in app.yml must be a number"},
+		{"url_path: [a]", "'url_path' in app.yml must be a string"},
+		{"readiness_path: health", "invalid readiness_path in app.yml: health"},
+		{"readiness_path: /health?full=1", "invalid readiness_path in app.yml: /health?full=1"},
+		{"description: 5", "'description' in app.yml must be a string"},
+		{"homepage: ftp://example.com", "invalid homepage in app.yml, it must be an http or https URL: ftp://example.com"},
+		{"homepage: example.com", "invalid homepage in app.yml, it must be an http or https URL: example.com"},
+		{"license: MIT License", "invalid license in app.yml, it must be an SPDX license identifier: MIT License"},
+		{"icon: ../icon.png", "invalid icon in app.yml, it must be the name of a png, jpg, svg or webp file in the zip: ../icon.png"},
+		{"icon: icon.exe", "invalid icon in app.yml, it must be the name of a png, jpg, svg or webp file in the zip: icon.exe"},
+		{"categories: git", "'categories' in app.yml must be a list of strings"},
+		{"categories: [Git]", "invalid category in app.yml, it must consist of lowercase letters, digits and dashes: Git"},
+		{"categories: [git, git]", "duplicate category in app.yml: git"},
+		{"categories: [a, b, c, d, e, f, g, h, i, j, k]", "too many categories in app.yml, at most 10 are allowed"},
+		{"required_environment: [A]", "'required_environment' in app.yml must be a list of maps with 'name' and 'description'"},
+		{"required_environment: [{name: 1A, description: a}]", "invalid name of required environment variable in app.yml: 1A"},
+		{"required_environment: [{name: A}]", "required environment variable 'A' in app.yml needs a description"},
+		{"required_environment: [{name: A, description: a, default: b}]", "not allowed key in app.yml: required_environment.default"},
+		{"required_environment: [{name: A, description: a}, {name: A, description: b}]", "duplicate required environment variable in app.yml: A"},
+		{"min_ocelot_cloud_version: latest", "invalid min_ocelot_cloud_version in app.yml, it must look like '1.2.3': latest"},
+		{"capabilities: [CAP_NET_RAW]", "'capabilities' in app.yml must be a list of maps with 'name' and 'justification'"},
+		{"capabilities: [{name: NET_RAW, justification: a}]", "invalid capability in app.yml, it must look like 'CAP_NET_RAW': NET_RAW"},
+		{"capabilities: [{name: CAP_NET_RAW}]", "capability 'CAP_NET_RAW' in app.yml needs a justification"},
+		{"capabilities: [{name: CAP_NET_RAW, justification: a}, {name: CAP_NET_RAW, justification: b}]", "duplicate capability in app.yml: CAP_NET_RAW"},
+		{"- port", "failed to parse app.yml: line 1: app.yml must be a map"},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParseAppManifest([]byte(tc.content))
+		assert.NotNil(t, err, tc.content)
+		if err != nil {
+			assert.Equal(t, tc.expectedError, err.Error())
+		}
+	}
+}
+
+func TestAllowIconFile(t *testing.T) {
+	zipBytes, err := ZipDirectory(getSamplesComposeDir() + "/with-icon")
+	assert.Nil(t, err)
+	assert.Nil(t, ValidateVersion(zipBytes, maintainerName, appName))
+}
+
+func TestMissingIconFile(t *testing.T) {
+	zipBytes, err := ZipDirectory(getSamplesComposeDir() + "/missing-icon")
+	assert.Nil(t, err)
+	err = ValidateVersion(zipBytes, maintainerName, appName)
+	assert.NotNil(t, err)
+	assert.Equal(t, "icon file declared in app.yml is missing in zip: icon.png", err.Error())
+}
+
+func TestInvalidIconFile(t *testing.T) {
+	testCases := []struct {
+		fileName      string
+		content       string
+		expectedError string
+	}{
+		{"icon.png", "not a png", "icon file icon.png is not a valid png image"},
+		{"icon.jpg", "not a jpeg", "icon file icon.jpg is not a valid jpeg image"},
+		{"icon.svg", `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`, "icon file icon.svg is not a valid svg image: element 'script' is not allowed"},
+	}
+
+	for _, tc := range testCases {
+		dir := t.TempDir()
+		assert.Nil(t, copyFile(getSamplesComposeDir()+"/with-icon/docker-compose.yml", filepath.Join(dir, composeFileName)))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, appYamlFileName), []byte("icon: "+tc.fileName), 0600))
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, tc.fileName), []byte(tc.content), 0600))
+		zipBytes, err := ZipDirectory(dir)
+		assert.Nil(t, err)
+		err = ValidateVersion(zipBytes, maintainerName, appName)
+		assert.NotNil(t, err)
+		assert.Equal(t, tc.expectedError, err.Error())
+	}
+}
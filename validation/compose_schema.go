@@ -0,0 +1,271 @@
+package validation
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/ocelot-cloud/shared/compose"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+	"strconv"
+	"strings"
+)
+
+// composeSpecJson is the schema of the compose specification, vendored verbatim from schema/compose-spec.json of
+// github.com/compose-spec/compose-go/v2 v2.1.3. Update it by copying the file of a newer release.
+//
+//go:embed schema/compose-spec.json
+var composeSpecJson []byte
+
+const composeSpecUrl = "compose-spec.json"
+
+var composeSpecSchema = mustCompileJsonSchema(composeSpecUrl, composeSpecJson)
+
+var composeSchemaViolation = "docker-compose.yml does not match the compose specification at '%s': %s"
+
+// maxComposeNodes limits the number of yaml values of a docker-compose.yml with all aliases expanded. Aliases of
+// aliases expand exponentially, so a small file could otherwise keep the schema validation busy for a long time.
+const maxComposeNodes = 10000
+
+var composeFileTooComplex = "docker-compose.yml expands to more than %d values through yaml aliases"
+
+func mustCompileJsonSchema(url string, data []byte) *jsonschema.Schema {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, bytes.NewReader(data)); err != nil {
+		panic(fmt.Sprintf("failed to parse bundled json schema: %v", err))
+	}
+	schema, err := compiler.Compile(url)
+	if err != nil {
+		panic(fmt.Sprintf("failed to compile bundled json schema: %v", err))
+	}
+	return schema
+}
+
+// schemaViolation is a single mismatch between a yaml document and a schema. Path is the dot separated path of
+// the value, like "services.gitea.ports.0".
+type schemaViolation struct {
+	path    []string
+	node    *yaml.Node
+	message string
+}
+
+func (v schemaViolation) pathString() string {
+	if len(v.path) == 0 {
+		return "(root)"
+	}
+	return strings.Join(v.path, ".")
+}
+
+// validateAgainstSchema validates the yaml document and returns the violations with the yaml nodes they refer to, so
+// that they can be reported with their position. The aliases of the document must have been limited before, see
+// countExpandedNodes, since they are expanded.
+func validateAgainstSchema(schema *jsonschema.Schema, document *yaml.Node) []schemaViolation {
+	if document.Kind == yaml.DocumentNode {
+		if len(document.Content) == 0 {
+			return nil
+		}
+		document = document.Content[0]
+	}
+	if yamlNodeType(document) == "null" {
+		return nil
+	}
+	d := &schemaDocument{values: make(map[string]*yaml.Node), keys: make(map[string]*yaml.Node)}
+	err := schema.Validate(d.toJson(document, ""))
+	if err == nil {
+		return nil
+	}
+	var validationError *jsonschema.ValidationError
+	if !errors.As(err, &validationError) {
+		return []schemaViolation{{node: document, message: err.Error()}}
+	}
+	return d.collectViolations(validationError)
+}
+
+// schemaDocument maps the json pointers of the validation errors back to the yaml nodes of the document.
+type schemaDocument struct {
+	values map[string]*yaml.Node
+	keys   map[string]*yaml.Node
+}
+
+// toJson converts the yaml value to the representation of the json schema validator and records the nodes of every
+// value by its json pointer.
+func (d *schemaDocument) toJson(node *yaml.Node, pointer string) interface{} {
+	node = compose.ResolveAlias(node)
+	d.values[pointer] = node
+	switch node.Kind {
+	case yaml.MappingNode:
+		object := make(map[string]interface{})
+		for _, entry := range compose.MappingEntries(node) {
+			key := entry[0].Value
+			d.keys[pointer+"/"+escapePointer(key)] = entry[0]
+			object[key] = d.toJson(entry[1], pointer+"/"+escapePointer(key))
+		}
+		return object
+	case yaml.SequenceNode:
+		array := make([]interface{}, 0, len(node.Content))
+		for i, item := range node.Content {
+			array = append(array, d.toJson(item, pointer+"/"+strconv.Itoa(i)))
+		}
+		return array
+	}
+	switch yamlNodeType(node) {
+	case "null":
+		return nil
+	case "boolean":
+		var value bool
+		if node.Decode(&value) == nil {
+			return value
+		}
+	case "integer":
+		var value int64
+		if node.Decode(&value) == nil {
+			return value
+		}
+	case "number":
+		var value float64
+		if node.Decode(&value) == nil {
+			return value
+		}
+	}
+	return node.Value
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func unescapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// collectViolations flattens the tree of validation errors to the errors which caused it.
+func (d *schemaDocument) collectViolations(err *jsonschema.ValidationError) []schemaViolation {
+	if isAlternativesError(err) {
+		return d.collectAlternativeViolations(err)
+	}
+	if strings.HasSuffix(err.KeywordLocation, "/additionalProperties") && len(err.Causes) == 0 {
+		return d.additionalPropertyViolations(err)
+	}
+	if len(err.Causes) == 0 {
+		return []schemaViolation{d.violation(err.InstanceLocation, d.values[err.InstanceLocation], err.Message)}
+	}
+	var violations []schemaViolation
+	for _, cause := range err.Causes {
+		violations = append(violations, d.collectViolations(cause)...)
+	}
+	return violations
+}
+
+func isAlternativesError(err *jsonschema.ValidationError) bool {
+	return len(err.Causes) > 0 && (strings.HasSuffix(err.KeywordLocation, "/oneOf") || strings.HasSuffix(err.KeywordLocation, "/anyOf"))
+}
+
+// collectAlternativeViolations handles a value matching none of the alternatives of 'oneOf' or 'anyOf'. The
+// violations of the only alternative accepting the type of the value are the most helpful ones. If no alternative
+// accepts the type, the allowed types are reported instead.
+func (d *schemaDocument) collectAlternativeViolations(err *jsonschema.ValidationError) []schemaViolation {
+	var candidates []*jsonschema.ValidationError
+	var allowedTypes []string
+	actualType := ""
+	for _, cause := range err.Causes {
+		if expected, actual, ok := typeMismatch(cause, err.InstanceLocation); ok {
+			allowedTypes = append(allowedTypes, expected)
+			actualType = actual
+		} else {
+			candidates = append(candidates, cause)
+		}
+	}
+	if len(candidates) == 1 {
+		return d.collectViolations(candidates[0])
+	}
+	message := "does not match any of the allowed forms"
+	if len(candidates) == 0 && len(allowedTypes) > 0 {
+		message = fmt.Sprintf("expected %s, but got %s", strings.Join(allowedTypes, " or "), actualType)
+	}
+	return []schemaViolation{d.violation(err.InstanceLocation, d.values[err.InstanceLocation], message)}
+}
+
+// typeMismatch tells whether the alternative failed only because the value at the location has the wrong type.
+func typeMismatch(err *jsonschema.ValidationError, location string) (string, string, bool) {
+	for len(err.Causes) == 1 && err.Causes[0].InstanceLocation == location {
+		err = err.Causes[0]
+	}
+	if len(err.Causes) > 0 || err.InstanceLocation != location || !strings.HasSuffix(err.KeywordLocation, "/type") {
+		return "", "", false
+	}
+	expected, actual, found := strings.Cut(strings.TrimPrefix(err.Message, "expected "), ", but got ")
+	return expected, actual, found
+}
+
+// additionalPropertyViolations reports each key which is not allowed at the position of the key. The validator lists
+// all of them in a single message in random order.
+func (d *schemaDocument) additionalPropertyViolations(err *jsonschema.ValidationError) []schemaViolation {
+	mapping := d.values[err.InstanceLocation]
+	var violations []schemaViolation
+	for _, entry := range compose.MappingEntries(mapping) {
+		key := entry[0].Value
+		if strings.Contains(err.Message, quoteProperty(key)) {
+			location := err.InstanceLocation + "/" + escapePointer(key)
+			violations = append(violations, d.violation(location, d.keys[location], "is not allowed"))
+		}
+	}
+	if len(violations) == 0 {
+		return []schemaViolation{d.violation(err.InstanceLocation, mapping, err.Message)}
+	}
+	return violations
+}
+
+// quoteProperty quotes a property name like the validator does in its messages.
+func quoteProperty(name string) string {
+	quoted := fmt.Sprintf("%q", name)
+	quoted = strings.ReplaceAll(quoted, `\"`, `"`)
+	quoted = strings.ReplaceAll(quoted, `'`, `\'`)
+	return "'" + quoted[1:len(quoted)-1] + "'"
+}
+
+func (d *schemaDocument) violation(location string, node *yaml.Node, message string) schemaViolation {
+	var path []string
+	if location != "" {
+		for _, token := range strings.Split(strings.TrimPrefix(location, "/"), "/") {
+			path = append(path, unescapePointer(token))
+		}
+	}
+	return schemaViolation{path: path, node: node, message: message}
+}
+
+// countExpandedNodes returns the number of yaml values below the node with all aliases expanded. It stops counting
+// once the limit is exceeded, so that the count is cheap even for documents which expand to billions of values.
+func countExpandedNodes(node *yaml.Node, limit int) int {
+	count := 1
+	node = compose.ResolveAlias(node)
+	for _, child := range node.Content {
+		if count > limit {
+			break
+		}
+		count += countExpandedNodes(child, limit-count)
+	}
+	return count
+}
+
+// yamlNodeType returns the JSON schema type of a yaml value.
+func yamlNodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	default:
+		return "string"
+	}
+}
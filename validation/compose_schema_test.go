@@ -0,0 +1,79 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/ocelot-cloud/shared/assert"
+	"gopkg.in/yaml.v3"
+	"os"
+	"testing"
+)
+
+func TestComposeSchema(t *testing.T) {
+	testCases := []struct {
+		content            string
+		expectedViolations []string
+	}{
+		{"", nil},
+		{"services:\n  app:\n    image: app:1.0\n    tty: true\n    deploy:\n      resources:\n        limits:\n          cpus: 0.5", nil},
+		{"services:\n  app:\n    volumes:\n      - type: volume\n        source: data\n        target: /data", nil},
+		{"services:\n  app:\n    depends_on:\n      db:\n        condition: service_healthy", nil},
+		{"x-common: &common\n  image: app:1.0\nservices:\n  app:\n    <<: *common\n    tty: true", nil},
+		{"services:\n  app:\n    <<: {imagee: app:1.0}", []string{"'services.app.imagee' is not allowed"}},
+		{"hello", []string{"'(root)' expected object, but got string"}},
+		{"services:\n  app:\n    image: 1", []string{"'services.app.image' expected string, but got number"}},
+		{"services:\n  app:\n    unknown: 1\n    other: 2", []string{"'services.app.unknown' is not allowed", "'services.app.other' is not allowed"}},
+		{"services:\n  app:\n    pull_policy: sometimes", []string{`'services.app.pull_policy' value must be one of "always", "never", "if_not_present", "build", "missing"`}},
+		{"services:\n  app:\n    oom_score_adj: 2000", []string{"'services.app.oom_score_adj' must be <= 1000 but found 2000"}},
+		{"services:\n  app:\n    cap_drop: [ALL, ALL]", []string{"'services.app.cap_drop' items at index 0 and 1 are equal"}},
+		{"services:\n  app:\n    command: {a: b}", []string{"'services.app.command' expected null or string or array, but got object"}},
+		{"services:\n  app:\n    volumes:\n      - source: data", []string{"'services.app.volumes.0' missing properties: 'type'"}},
+		{"services:\n  app:\n    depends_on:\n      db:\n        condition: started", []string{`'services.app.depends_on.db.condition' value must be one of "service_started", "service_healthy", "service_completed_successfully"`}},
+		{"services:\n  app:\n    environment:\n      A: [b]", []string{"'services.app.environment.A' expected string or number or boolean or null, but got array"}},
+		{"name: My-App", []string{"'name' does not match pattern '^[a-z0-9][a-z0-9_-]*$'"}},
+	}
+
+	for _, tc := range testCases {
+		var document yaml.Node
+		assert.Nil(t, yaml.Unmarshal([]byte(tc.content), &document))
+		violations := validateAgainstSchema(composeSpecSchema, &document)
+		var actual []string
+		for _, violation := range violations {
+			actual = append(actual, fmt.Sprintf("'%s' %s", violation.pathString(), violation.message))
+		}
+		assert.Equal(t, tc.expectedViolations, actual, tc.content)
+	}
+}
+
+func TestComposeSchemaViolationPosition(t *testing.T) {
+	var document yaml.Node
+	assert.Nil(t, yaml.Unmarshal([]byte("services:\n  app:\n    image: app:1.0\n    unknown: 1"), &document))
+	violations := validateAgainstSchema(composeSpecSchema, &document)
+	assert.Equal(t, 1, len(violations))
+	assert.Equal(t, 4, violations[0].node.Line)
+	assert.Equal(t, 5, violations[0].node.Column)
+}
+
+func TestComposeConsistency(t *testing.T) {
+	testCases := []struct {
+		content       string
+		expectedError string
+	}{
+		{"volumes:\n  samplemaintainer_gitea_data:\nservices:\n  gitea:\n    image: gitea/gitea:1.20.2\n    container_name: samplemaintainer_gitea_gitea\n    volumes:\n      - samplemaintainer_gitea_data:/data\n    depends_on:\n      - gitea", ""},
+		{"services:\n  gitea:\n    image: gitea/gitea:1.20.2\n    container_name: samplemaintainer_gitea_gitea\n    depends_on:\n      - database", fmt.Sprintf(undefinedServiceDependency, "gitea", "database")},
+		{"services:\n  gitea:\n    image: gitea/gitea:1.20.2\n    container_name: samplemaintainer_gitea_gitea\n    volumes:\n      - samplemaintainer_gitea_data:/data", fmt.Sprintf(undefinedVolumeInService, "gitea", "samplemaintainer_gitea_data")},
+	}
+
+	for _, tc := range testCases {
+		dir := t.TempDir()
+		assert.Nil(t, os.WriteFile(dir+"/"+composeFileName, []byte(tc.content), 0600))
+		zipBytes, err := ZipDirectory(dir)
+		assert.Nil(t, err)
+		err = ValidateVersion(zipBytes, maintainerName, appName)
+		if tc.expectedError == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.NotNil(t, err)
+			assert.Equal(t, tc.expectedError, err.Error())
+		}
+	}
+}
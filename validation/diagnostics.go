@@ -0,0 +1,150 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ocelot-cloud/shared/compose"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"strconv"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Rule IDs identify the check which produced a Finding.
+const (
+	RuleReservedAppName     = "reserved-app-name"
+	RuleArchive             = "archive"
+	RuleAppYaml             = "app-yaml"
+	RuleComposeSyntax       = "compose-syntax"
+	RuleComposeSchema       = "compose-schema"
+	RuleTopLevelKey         = "top-level-key"
+	RuleServiceKey          = "service-key"
+	RuleImage               = "image"
+	RuleContainerName       = "container-name"
+	RuleMainService         = "main-service"
+	RuleReservedPort        = "reserved-port"
+	RuleVolumeMount         = "volume-mount"
+	RuleHostNetwork         = "host-network"
+	RuleDeploy              = "deploy"
+	RuleDeviceReservation   = "device-reservation"
+	RuleGlobalVolume        = "global-volume"
+	RuleComposeConsistency  = "compose-consistency"
+	RuleVariable            = "variable"
+	RuleHardcodedCredential = "hardcoded-credential"
+	RuleHealthcheck         = "healthcheck"
+	RuleEnvironment         = "environment"
+	RuleCapability          = "capability"
+)
+
+const (
+	composeFileName = "docker-compose.yml"
+	appYamlFileName = "app.yml"
+)
+
+// Finding is a single problem found in an app version. File, Line and Column point to the location of the problem
+// if known, Service is set if the problem belongs to a service of the docker-compose.yml.
+type Finding struct {
+	RuleID   string   `json:"rule_id"`
+	Severity Severity `json:"severity"`
+	Service  string   `json:"service,omitempty"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (f Finding) String() string {
+	location := f.File
+	if location != "" && f.Line > 0 {
+		location += fmt.Sprintf(":%d:%d", f.Line, f.Column)
+	}
+	if location == "" {
+		return fmt.Sprintf("%s: %s [%s]", f.Severity, f.Message, f.RuleID)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", location, f.Severity, f.Message, f.RuleID)
+}
+
+// HasErrors tells whether any of the findings has error severity.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// findingsToError joins the messages of all findings with error severity, or returns nil if there are none.
+func findingsToError(findings []Finding) error {
+	var errs []error
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			errs = append(errs, errors.New(finding.Message))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type report struct {
+	findings []Finding
+}
+
+func (r *report) add(finding Finding) {
+	r.findings = append(r.findings, finding)
+}
+
+func (r *report) addError(ruleID, file string, err error) {
+	r.addErrorAt(ruleID, file, 0, err)
+}
+
+// addErrorAt reports an error at the line of the file, which is unknown if it is 0.
+func (r *report) addErrorAt(ruleID, file string, line int, err error) {
+	r.add(Finding{RuleID: ruleID, Severity: SeverityError, File: file, Line: line, Message: err.Error()})
+}
+
+func (r *report) addComposeFinding(ruleID string, severity Severity, serviceName string, position compose.Position, message string) {
+	r.add(Finding{
+		RuleID:   ruleID,
+		Severity: severity,
+		Service:  serviceName,
+		File:     composeFileName,
+		Line:     position.Line,
+		Column:   position.Column,
+		Message:  message,
+	})
+}
+
+func (r *report) hasErrors() bool {
+	return HasErrors(r.findings)
+}
+
+var yamlErrorLineRegex = regexp.MustCompile(`^(?:yaml: )?line (\d+):`)
+
+// yamlErrorLine returns the line of an error of the yaml parser, which starts its messages with it, like
+// "yaml: line 3: did not find expected key". Only the start of the message is considered, since the rest may contain
+// keys and values of the uploaded file. It returns 0 for other errors.
+func yamlErrorLine(err error) int {
+	var typeError *yaml.TypeError
+	if errors.As(err, &typeError) && len(typeError.Errors) > 0 {
+		return lineOfYamlMessage(typeError.Errors[0])
+	}
+	for errors.Unwrap(err) != nil {
+		err = errors.Unwrap(err)
+	}
+	return lineOfYamlMessage(err.Error())
+}
+
+func lineOfYamlMessage(message string) int {
+	match := yamlErrorLineRegex.FindStringSubmatch(message)
+	if match == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(match[1])
+	return line
+}
@@ -0,0 +1,99 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ocelot-cloud/shared/assert"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func TestDiagnoseVersionReportsAllFindings(t *testing.T) {
+	zipBytes, err := createZipWithComposeFile(getSamplesComposeDir(), "multiple-problems.yml")
+	assert.Nil(t, err)
+	findings := DiagnoseVersion(zipBytes, maintainerName, appName, DefaultValidationOptions())
+
+	expectedFindings := []Finding{
+		{RuleID: RuleTopLevelKey, Line: 16, Column: 1, Message: fmt.Sprintf(notAllowedTopLevelKeyword, "configs")},
+		{RuleID: RuleServiceKey, Service: "gitea", Line: 5, Column: 5, Message: fmt.Sprintf(notAllowedKeyInService, "gitea", "privileged")},
+		{RuleID: RuleImage, Service: "gitea", Line: 3, Column: 5, Message: notAllowedLatestDockerImageTag},
+		{RuleID: RuleReservedPort, Service: "gitea", Line: 8, Column: 9, Message: fmt.Sprintf(notAllowedExposingDefaultHttpPorts, "80")},
+		{RuleID: RuleVolumeMount, Service: "gitea", Line: 10, Column: 9, Message: fmt.Sprintf(notAllowedMountingHostDirectories, "gitea")},
+		{RuleID: RuleContainerName, Service: "giteadb", Line: 14, Column: 5, Message: fmt.Sprintf(wrongContainerNamePrefix, expectedPrefix)},
+	}
+	assert.Equal(t, len(expectedFindings), len(findings))
+	for i, expected := range expectedFindings {
+		expected.Severity = SeverityError
+		expected.File = composeFileName
+		assert.Equal(t, expected, findings[i])
+	}
+	assert.True(t, HasErrors(findings))
+}
+
+func TestDiagnoseVersionOfValidApp(t *testing.T) {
+	zipBytes, err := ZipDirectory(getSamplesComposeDir() + "/allow-app-yml")
+	assert.Nil(t, err)
+	findings := DiagnoseVersion(zipBytes, maintainerName, appName, DefaultValidationOptions())
+	assert.False(t, HasErrors(findings))
+}
+
+func TestDiagnoseVersionReportsSchemaViolationLine(t *testing.T) {
+	zipBytes, err := createZipWithComposeFile(getSamplesComposeDir(), "ports-is-a-map.yml")
+	assert.Nil(t, err)
+	findings := DiagnoseVersion(zipBytes, maintainerName, appName, DefaultValidationOptions())
+	assert.Equal(t, 1, len(findings))
+	assert.Equal(t, RuleComposeSchema, findings[0].RuleID)
+	assert.Equal(t, "gitea", findings[0].Service)
+	assert.Equal(t, composeFileName, findings[0].File)
+	assert.Equal(t, 6, findings[0].Line)
+}
+
+func TestDiagnoseVersionReportsAppYamlPosition(t *testing.T) {
+	zipBytes, err := ZipDirectory(getSamplesComposeDir() + "/invalid-app-yml")
+	assert.Nil(t, err)
+	findings := DiagnoseVersion(zipBytes, maintainerName, appName, DefaultValidationOptions())
+	assert.Equal(t, 1, len(findings))
+	assert.Equal(t, Finding{RuleID: RuleAppYaml, Severity: SeverityError, File: appYamlFileName, Line: 1, Column: 1, Message: "invalid port in app.yml: 123456"}, findings[0])
+}
+
+func TestYamlErrorLine(t *testing.T) {
+	var value struct{ Port int }
+	syntaxError := yaml.Unmarshal([]byte("a: b\n  c: d"), &value)
+	typeError := yaml.Unmarshal([]byte("\nport: abc"), &value)
+	testCases := []struct {
+		err      error
+		expected int
+	}{
+		{syntaxError, 2},
+		{typeError, 2},
+		{fmt.Errorf("failed to parse app.yml: %w", syntaxError), 2},
+		{fmt.Errorf("failed to parse docker-compose.yml: %w", errors.New("line 4: port must be a string or a map")), 4},
+		{fmt.Errorf(notAllowedKeyInAppYaml, "line 99"), 0},
+		{errors.New("image 'line 7: app' is invalid"), 0},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, yamlErrorLine(tc.err), tc.err.Error())
+	}
+}
+
+func TestUploadedTextDoesNotSetLine(t *testing.T) {
+	r := &report{}
+	manifest := &AppManifest{Capabilities: []CapabilityRequest{{Name: "line 99: CAP_SYS_ADMIN"}}}
+	validateCapabilityRequests(r, manifest, DefaultPolicy())
+	assert.Equal(t, 1, len(r.findings))
+	assert.Equal(t, 0, r.findings[0].Line)
+}
+
+func TestFindingString(t *testing.T) {
+	finding := Finding{RuleID: RuleImage, Severity: SeverityError, Service: "gitea", File: composeFileName, Line: 3, Column: 5, Message: "some message"}
+	assert.Equal(t, "docker-compose.yml:3:5: error: some message [image]", finding.String())
+	finding = Finding{RuleID: RuleArchive, Severity: SeverityError, Message: "zip file is empty"}
+	assert.Equal(t, "error: zip file is empty [archive]", finding.String())
+}
+
+func TestFindingsToError(t *testing.T) {
+	assert.Nil(t, findingsToError(nil))
+	assert.Nil(t, findingsToError([]Finding{{Severity: SeverityWarning, Message: "a"}}))
+	err := findingsToError([]Finding{{Severity: SeverityError, Message: "a"}, {Severity: SeverityWarning, Message: "b"}, {Severity: SeverityError, Message: "c"}})
+	assert.Equal(t, errors.Join(errors.New("a"), errors.New("c")).Error(), err.Error())
+}
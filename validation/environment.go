@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"github.com/ocelot-cloud/shared/compose"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	invalidEnvironmentVariableName  = "invalid name of environment variable '%s' in service '%s', it must consist of letters, digits, underscores and dots and must not start with a digit"
+	environmentValueTooLong         = "value of environment variable '%s' in service '%s' is longer than %d characters"
+	notAllowedEnvironmentVariable   = "environment variable '%s' in service '%s' is not allowed, as it changes how the container reaches the docker daemon, the network or loads libraries"
+	environmentVariableWithoutValue = "environment variable '%s' in service '%s' has no value, which would pass through the value of the host, set a value or reference a platform variable like ${%s}"
+	envFileNotAllowed               = "'env_file' in service '%s' is not allowed, as it reads files from the host, declare the variables in 'environment' instead"
+)
+
+const maxEnvironmentValueLength = 4096
+
+var environmentVariableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]{0,127}$`)
+
+// deniedEnvironmentVariables are compared case-insensitively, since some tools also read the lowercase proxy variables.
+var deniedEnvironmentVariables = []string{
+	"DOCKER_HOST", "DOCKER_TLS_VERIFY", "DOCKER_CERT_PATH", "DOCKER_CONFIG", "DOCKER_CONTEXT",
+	"HTTP_PROXY", "HTTPS_PROXY", "FTP_PROXY", "ALL_PROXY", "NO_PROXY",
+	"LD_PRELOAD", "LD_LIBRARY_PATH", "LD_AUDIT",
+}
+
+// validateEnvironment checks the names and values of the environment variables of the service. References to
+// unknown variables in the values are reported by validateVariables.
+func (v *composeValidator) validateEnvironment(serviceName string, service *compose.Service) {
+	for i, variable := range service.Environment.Variables {
+		position := v.servicePosition(serviceName, "environment", environmentEntry(service, i))
+		switch {
+		case !environmentVariableNameRegex.MatchString(variable.Name):
+			v.addError(RuleEnvironment, serviceName, position, invalidEnvironmentVariableName, variable.Name, serviceName)
+		case isDeniedEnvironmentVariable(variable.Name):
+			v.addError(RuleEnvironment, serviceName, position, notAllowedEnvironmentVariable, variable.Name, serviceName)
+		case variable.Value == nil:
+			v.addError(RuleEnvironment, serviceName, position, environmentVariableWithoutValue, variable.Name, serviceName, VariableHost)
+		case len(*variable.Value) > maxEnvironmentValueLength:
+			v.addError(RuleEnvironment, serviceName, position, environmentValueTooLong, variable.Name, serviceName, maxEnvironmentValueLength)
+		}
+	}
+}
+
+// environmentEntry returns the path element of the i-th environment variable, which is the index in list syntax and
+// the name in map syntax.
+func environmentEntry(service *compose.Service, i int) string {
+	if service.Environment.MapSyntax {
+		return service.Environment.Variables[i].Name
+	}
+	return strconv.Itoa(i)
+}
+
+func isDeniedEnvironmentVariable(name string) bool {
+	for _, denied := range deniedEnvironmentVariables {
+		if strings.EqualFold(name, denied) {
+			return true
+		}
+	}
+	return false
+}
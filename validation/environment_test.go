@@ -0,0 +1,51 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/ocelot-cloud/shared/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func diagnoseComposeContent(t *testing.T, content string) []Finding {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, composeFileName), []byte(content), 0600))
+	zipBytes, err := ZipDirectory(dir)
+	assert.Nil(t, err)
+	return DiagnoseVersion(zipBytes, maintainerName, appName, DefaultValidationOptions())
+}
+
+func TestEnvironmentValidation(t *testing.T) {
+	header := "services:\n  gitea:\n    image: gitea/gitea:1.20.2\n    container_name: samplemaintainer_gitea_gitea\n    environment:\n"
+	testCases := []struct {
+		environment     string
+		expectedMessage string
+	}{
+		{"      - USER_UID=1000\n      - server.port=3000\n      - EMPTY=", ""},
+		{"      APP_URL: ${APP_URL}\n      TZ: ${TIMEZONE}", ""},
+		{"      - 1USER=a", fmt.Sprintf(invalidEnvironmentVariableName, "1USER", "gitea")},
+		{"      USER-ID: a", fmt.Sprintf(invalidEnvironmentVariableName, "USER-ID", "gitea")},
+		{"      - https_proxy=http://proxy:3128", fmt.Sprintf(notAllowedEnvironmentVariable, "https_proxy", "gitea")},
+		{"      LD_PRELOAD: /lib/evil.so", fmt.Sprintf(notAllowedEnvironmentVariable, "LD_PRELOAD", "gitea")},
+		{"      - USER_UID", fmt.Sprintf(environmentVariableWithoutValue, "USER_UID", "gitea", VariableHost)},
+		{"      USER_UID:", fmt.Sprintf(environmentVariableWithoutValue, "USER_UID", "gitea", VariableHost)},
+		{"      BLOB: " + strings.Repeat("a", maxEnvironmentValueLength+1), fmt.Sprintf(environmentValueTooLong, "BLOB", "gitea", maxEnvironmentValueLength)},
+		{"      DOMAIN: ${DOMAIN}", fmt.Sprintf(unknownVariable, "DOMAIN", strings.Join(platformVariables, ", "), SecretVariablePrefix)},
+	}
+
+	for _, tc := range testCases {
+		findings := diagnoseComposeContent(t, header+tc.environment)
+		if tc.expectedMessage == "" {
+			assert.Equal(t, 0, len(findings), tc.environment)
+			continue
+		}
+		assert.Equal(t, 1, len(findings), tc.environment)
+		if len(findings) == 1 {
+			assert.Equal(t, tc.expectedMessage, findings[0].Message)
+			assert.Equal(t, "gitea", findings[0].Service)
+			assert.Equal(t, 6, findings[0].Line)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/ocelot-cloud/shared/compose"
+	"strconv"
+	"strings"
+)
+
+var capabilityNotRequestable = "capability '%s' requested in app.yml is not allowed by the hardening profile"
+
+// HardeningProfile configures the security settings CompleteDockerComposeYamlWithProfile applies to every service
+// of an app. Zero values leave the respective setting untouched.
+type HardeningProfile struct {
+	RestartPolicy    string
+	DropCapabilities []string
+	AddCapabilities  []string
+	// RequestableCapabilities lists the capabilities apps may additionally request with a justification in app.yml.
+	RequestableCapabilities []string
+	NoNewPrivileges         bool
+	ReadOnlyRootFilesystem  bool
+	// WritableTmpfsMounts are mounted into every service if ReadOnlyRootFilesystem is set, so that apps can still
+	// write temporary files.
+	WritableTmpfsMounts []string
+	// DefaultLimits are applied to services which do not declare the respective limit themselves.
+	DefaultLimits ResourceLimits
+	Logging       *LoggingLimits
+}
+
+type ResourceLimits struct {
+	Cpus   string
+	Memory string
+	Pids   int64
+}
+
+// LoggingLimits restricts the disk space used by the logs of a service.
+type LoggingLimits struct {
+	Driver  string
+	MaxSize string
+	MaxFile int
+}
+
+// DefaultHardeningProfile returns the settings which are applied by CompleteDockerComposeYaml.
+func DefaultHardeningProfile() HardeningProfile {
+	return HardeningProfile{
+		RestartPolicy:    "unless-stopped",
+		DropCapabilities: []string{"ALL"},
+		AddCapabilities: []string{
+			"CAP_NET_BIND_SERVICE", "CAP_CHOWN", "CAP_FOWNER",
+			"CAP_SETGID", "CAP_SETUID", "CAP_DAC_OVERRIDE",
+		},
+	}
+}
+
+// StrictHardeningProfile extends the default profile by all available restrictions with conservative defaults.
+func StrictHardeningProfile() HardeningProfile {
+	profile := DefaultHardeningProfile()
+	profile.NoNewPrivileges = true
+	profile.ReadOnlyRootFilesystem = true
+	profile.WritableTmpfsMounts = []string{"/tmp", "/run"}
+	profile.DefaultLimits = ResourceLimits{Cpus: "1", Memory: "1G", Pids: 512}
+	profile.Logging = &LoggingLimits{Driver: "json-file", MaxSize: "10m", MaxFile: 3}
+	return profile
+}
+
+// capabilitiesToAdd returns the capabilities of the profile plus the ones requested by the app, which must be requestable.
+func (p HardeningProfile) capabilitiesToAdd(manifest *AppManifest) ([]string, error) {
+	capabilities := append([]string{}, p.AddCapabilities...)
+	if manifest == nil {
+		return capabilities, nil
+	}
+	for _, request := range manifest.Capabilities {
+		if !contains(p.RequestableCapabilities, request.Name) {
+			return nil, fmt.Errorf(capabilityNotRequestable, request.Name)
+		}
+		if !contains(capabilities, request.Name) {
+			capabilities = append(capabilities, request.Name)
+		}
+	}
+	return capabilities, nil
+}
+
+func (p HardeningProfile) apply(service *compose.Service, capabilities []string) {
+	if p.RestartPolicy != "" {
+		service.Restart = p.RestartPolicy
+	}
+	if len(p.DropCapabilities) > 0 {
+		service.CapDrop = append([]string{}, p.DropCapabilities...)
+	}
+	if len(capabilities) > 0 {
+		service.CapAdd = append([]string{}, capabilities...)
+	}
+	if p.NoNewPrivileges && !contains(service.SecurityOpt, "no-new-privileges:true") {
+		service.SecurityOpt = append(service.SecurityOpt, "no-new-privileges:true")
+	}
+	if p.ReadOnlyRootFilesystem {
+		service.ReadOnly = true
+		addTmpfsMounts(service, p.WritableTmpfsMounts)
+	}
+	p.applyDefaultLimits(service)
+	if p.Logging != nil && service.Logging == nil {
+		service.Logging = p.Logging.toCompose()
+	}
+}
+
+func (p HardeningProfile) applyDefaultLimits(service *compose.Service) {
+	if p.DefaultLimits == (ResourceLimits{}) {
+		return
+	}
+	if service.Deploy == nil {
+		service.Deploy = &compose.Deploy{}
+	}
+	if service.Deploy.Resources == nil {
+		service.Deploy.Resources = &compose.Resources{}
+	}
+	if service.Deploy.Resources.Limits == nil {
+		service.Deploy.Resources.Limits = &compose.ResourceValues{}
+	}
+	limits := service.Deploy.Resources.Limits
+	if limits.Cpus == "" {
+		limits.Cpus = p.DefaultLimits.Cpus
+	}
+	if limits.Memory == "" {
+		limits.Memory = p.DefaultLimits.Memory
+	}
+	if limits.Pids == 0 {
+		limits.Pids = p.DefaultLimits.Pids
+	}
+}
+
+func (l LoggingLimits) toCompose() *compose.Logging {
+	logging := &compose.Logging{Driver: l.Driver, Options: map[string]string{}}
+	if l.MaxSize != "" {
+		logging.Options["max-size"] = l.MaxSize
+	}
+	if l.MaxFile > 0 {
+		logging.Options["max-file"] = strconv.Itoa(l.MaxFile)
+	}
+	return logging
+}
+
+// addTmpfsMounts adds the mounts unless the service already mounts a tmpfs at the same path.
+func addTmpfsMounts(service *compose.Service, mounts []string) {
+	for _, mount := range mounts {
+		alreadyMounted := false
+		for _, existing := range service.Tmpfs.Values {
+			if strings.SplitN(existing, ":", 2)[0] == mount {
+				alreadyMounted = true
+				break
+			}
+		}
+		if !alreadyMounted {
+			service.Tmpfs.Values = append(service.Tmpfs.Values, mount)
+			service.Tmpfs.IsList = true
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package validation
+
+import (
+	"github.com/ocelot-cloud/shared/assert"
+	"github.com/ocelot-cloud/shared/compose"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func completeSampleWithProfile(t *testing.T, appYaml string, profile HardeningProfile) (string, error) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, composeFileName)
+	assert.Nil(t, copyFile(getSamplesDir()+"/yaml-keyword-completion/input.yml", composePath))
+	if appYaml != "" {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, appYamlFileName), []byte(appYaml), 0600))
+	}
+	return composePath, CompleteDockerComposeYamlWithProfile("samplemaintainer", "gitea", composePath, "my-domain.com", profile)
+}
+
+func TestCompleteDockerComposeYamlWithStrictProfile(t *testing.T) {
+	composePath, err := completeSampleWithProfile(t, "", StrictHardeningProfile())
+	assert.Nil(t, err)
+	expectedBytes, err := os.ReadFile(getSamplesDir() + "/yaml-keyword-completion/expected-output-strict.yml")
+	assert.Nil(t, err)
+	actualBytes, err := os.ReadFile(composePath)
+	assert.Nil(t, err)
+	AssertYamlEquality(t, expectedBytes, actualBytes)
+}
+
+func TestHardeningKeepsDeclaredLimitsAndTmpfs(t *testing.T) {
+	service := &compose.Service{
+		Deploy: &compose.Deploy{Resources: &compose.Resources{Limits: &compose.ResourceValues{Memory: "256M"}}},
+		Tmpfs:  compose.StringOrList{Values: []string{"/tmp:size=64m"}},
+	}
+	profile := StrictHardeningProfile()
+	profile.apply(service, profile.AddCapabilities)
+	assert.Equal(t, &compose.ResourceValues{Cpus: "1", Memory: "256M", Pids: 512}, service.Deploy.Resources.Limits)
+	assert.Equal(t, []string{"/tmp:size=64m", "/run"}, service.Tmpfs.Values)
+}
+
+func TestCapabilityRequestsFromAppYaml(t *testing.T) {
+	appYaml := "capabilities:\n  - name: CAP_NET_RAW\n    justification: needed to send ping requests\n"
+
+	_, err := completeSampleWithProfile(t, appYaml, DefaultHardeningProfile())
+	assert.NotNil(t, err)
+	assert.Equal(t, "capability 'CAP_NET_RAW' requested in app.yml is not allowed by the hardening profile", err.Error())
+
+	profile := DefaultHardeningProfile()
+	profile.RequestableCapabilities = []string{"CAP_NET_RAW"}
+	composePath, err := completeSampleWithProfile(t, appYaml, profile)
+	assert.Nil(t, err)
+	project, err := compose.Load(composePath)
+	assert.Nil(t, err)
+	for _, service := range project.Services {
+		assert.Equal(t, append(DefaultHardeningProfile().AddCapabilities, "CAP_NET_RAW"), service.CapAdd)
+	}
+}
+
+func TestCompletionRejectsInvalidAppYaml(t *testing.T) {
+	_, err := completeSampleWithProfile(t, "port: 123456", DefaultHardeningProfile())
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid port in app.yml: 123456", err.Error())
+}
@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/ocelot-cloud/shared/compose"
+	"regexp"
+	"time"
+)
+
+var (
+	invalidHealthcheck            = "invalid healthcheck in service '%s': %s"
+	invalidReadinessPathInAppYaml = "invalid readiness_path in app.yml: %s"
+)
+
+// durationBounds are the allowed ranges of the healthcheck durations. Too short intervals put load on the host,
+// too long ones delay the detection of broken apps.
+var durationBounds = []struct {
+	key      string
+	min, max time.Duration
+	value    func(healthcheck *compose.Healthcheck) string
+}{
+	{"interval", 5 * time.Second, 10 * time.Minute, func(h *compose.Healthcheck) string { return h.Interval }},
+	{"timeout", 1 * time.Second, 5 * time.Minute, func(h *compose.Healthcheck) string { return h.Timeout }},
+	{"start_period", 0, 30 * time.Minute, func(h *compose.Healthcheck) string { return h.StartPeriod }},
+	{"start_interval", 1 * time.Second, 1 * time.Minute, func(h *compose.Healthcheck) string { return h.StartInterval }},
+}
+
+const maxHealthcheckRetries = 20
+
+var readinessPathRegex = regexp.MustCompile(`^(/[a-zA-Z0-9._~-]*){1,10}$`)
+
+// Settings of the healthcheck generated from the readiness_path of the app.yml.
+const (
+	readinessInterval    = "30s"
+	readinessTimeout     = "5s"
+	readinessRetries     = 3
+	readinessStartPeriod = "1m"
+)
+
+func (v *composeValidator) validateHealthcheck(serviceName string, service *compose.Service) {
+	if service.Healthcheck == nil {
+		return
+	}
+	if err := validateHealthcheck(service.Healthcheck); err != nil {
+		v.addError(RuleHealthcheck, serviceName, v.servicePosition(serviceName, "healthcheck"), invalidHealthcheck, serviceName, err.Error())
+	}
+}
+
+func validateHealthcheck(healthcheck *compose.Healthcheck) error {
+	if healthcheck.Disable {
+		return nil
+	}
+	if err := validateHealthcheckTest(healthcheck.Test); err != nil {
+		return err
+	}
+	for _, bound := range durationBounds {
+		value := bound.value(healthcheck)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("'%s' must be a duration like '30s', but got: %s", bound.key, value)
+		}
+		if duration < bound.min || duration > bound.max {
+			return fmt.Errorf("'%s' must be between %v and %v, but got: %s", bound.key, bound.min, bound.max, value)
+		}
+	}
+	if retries := healthcheck.Retries; retries != nil && (*retries < 1 || *retries > maxHealthcheckRetries) {
+		return fmt.Errorf("'retries' must be between 1 and %d, but got: %d", maxHealthcheckRetries, *retries)
+	}
+	return nil
+}
+
+// validateHealthcheckTest accepts a shell command as string, or a list starting with CMD or CMD-SHELL followed by the command.
+func validateHealthcheckTest(test compose.StringOrList) error {
+	if !test.IsList {
+		if len(test.Values) == 0 || test.Values[0] == "" {
+			return fmt.Errorf("'test' must not be empty")
+		}
+		return nil
+	}
+	if len(test.Values) < 2 || (test.Values[0] != "CMD" && test.Values[0] != "CMD-SHELL") {
+		return fmt.Errorf("'test' must be a list starting with 'CMD' or 'CMD-SHELL' followed by the command, use 'disable: true' to disable the healthcheck")
+	}
+	return nil
+}
+
+// addReadinessHealthcheck adds a healthcheck requesting the readiness_path of the app.yml to the main service, unless
+// the service declares its own healthcheck. It relies on wget or curl being available in the image.
+func addReadinessHealthcheck(project *compose.Project, config RoutingConfig, manifest *AppManifest) {
+	if manifest == nil || manifest.ReadinessPath == "" {
+		return
+	}
+	service, ok := project.Services[config.Service]
+	if !ok || service.Healthcheck != nil {
+		return
+	}
+	url := fmt.Sprintf("http://localhost:%d%s", config.Port, manifest.ReadinessPath)
+	command := fmt.Sprintf("wget -q -O /dev/null %s || curl -fsS -o /dev/null %s || exit 1", url, url)
+	retries := readinessRetries
+	service.Healthcheck = &compose.Healthcheck{
+		Test:        compose.StringOrList{Values: []string{"CMD-SHELL", command}, IsList: true},
+		Interval:    readinessInterval,
+		Timeout:     readinessTimeout,
+		Retries:     &retries,
+		StartPeriod: readinessStartPeriod,
+	}
+}
@@ -0,0 +1,49 @@
+package validation
+
+import (
+	"github.com/ocelot-cloud/shared/assert"
+	"github.com/ocelot-cloud/shared/compose"
+	"testing"
+)
+
+func TestValidateHealthcheck(t *testing.T) {
+	retries := func(value int) *int { return &value }
+	command := compose.StringOrList{Values: []string{"CMD", "curl", "-f", "http://localhost"}, IsList: true}
+	testCases := []struct {
+		name          string
+		healthcheck   compose.Healthcheck
+		expectedError string
+	}{
+		{"minimal", compose.Healthcheck{Test: command}, ""},
+		{"shell string", compose.Healthcheck{Test: compose.StringOrList{Values: []string{"curl -f http://localhost"}}}, ""},
+		{"all bounds", compose.Healthcheck{Test: command, Interval: "10m", Timeout: "1s", Retries: retries(20), StartPeriod: "0s", StartInterval: "1m"}, ""},
+		{"disabled", compose.Healthcheck{Disable: true}, ""},
+		{"missing test", compose.Healthcheck{Interval: "30s"}, "'test' must not be empty"},
+		{"none", compose.Healthcheck{Test: compose.StringOrList{Values: []string{"NONE"}, IsList: true}}, "'test' must be a list starting with 'CMD' or 'CMD-SHELL' followed by the command, use 'disable: true' to disable the healthcheck"},
+		{"cmd without command", compose.Healthcheck{Test: compose.StringOrList{Values: []string{"CMD"}, IsList: true}}, "'test' must be a list starting with 'CMD' or 'CMD-SHELL' followed by the command, use 'disable: true' to disable the healthcheck"},
+		{"invalid duration", compose.Healthcheck{Test: command, Timeout: "ten seconds"}, "'timeout' must be a duration like '30s', but got: ten seconds"},
+		{"start period too long", compose.Healthcheck{Test: command, StartPeriod: "1h"}, "'start_period' must be between 0s and 30m0s, but got: 1h"},
+		{"too many retries", compose.Healthcheck{Test: command, Retries: retries(21)}, "'retries' must be between 1 and 20, but got: 21"},
+		{"no retries", compose.Healthcheck{Test: command, Retries: retries(0)}, "'retries' must be between 1 and 20, but got: 0"},
+	}
+
+	for _, tc := range testCases {
+		err := validateHealthcheck(&tc.healthcheck)
+		if tc.expectedError == "" {
+			assert.Nil(t, err, tc.name)
+			continue
+		}
+		assert.NotNil(t, err, tc.name)
+		if err != nil {
+			assert.Equal(t, tc.expectedError, err.Error())
+		}
+	}
+}
+
+func TestReadinessHealthcheckKeepsDeclaredHealthcheck(t *testing.T) {
+	declared := &compose.Healthcheck{Test: compose.StringOrList{Values: []string{"true"}}}
+	project := &compose.Project{Services: map[string]*compose.Service{"gitea": {Healthcheck: declared}}}
+	config := NewRoutingConfig("samplemaintainer", "gitea", "my-domain.com", nil)
+	addReadinessHealthcheck(project, config, &AppManifest{ReadinessPath: "/health"})
+	assert.Equal(t, declared, project.Services["gitea"].Healthcheck)
+}
@@ -0,0 +1,165 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultImageRegistry   = "docker.io"
+	officialImageNamespace = "library"
+	maxImageNameLength     = 255
+)
+
+var (
+	imageDomainRegex        = regexp.MustCompile(`^(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*|\[[a-fA-F0-9:]+\])(?::[0-9]{1,5})?$`)
+	imagePathComponentRegex = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	imageTagRegex           = regexp.MustCompile(`^\w[\w.-]{0,127}$`)
+	imageDigestRegex        = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+	sha256DigestRegex       = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// ImageReference is a parsed container image reference like 'registry:5000/org/app:1.2@sha256:...'.
+// Registry and Repository are normalized, so 'nginx' becomes 'docker.io' and 'library/nginx'.
+type ImageReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageReference parses an image reference following the grammar of the OCI distribution specification.
+func ParseImageReference(image string) (*ImageReference, error) {
+	if image == "" {
+		return nil, fmt.Errorf("image reference is empty")
+	}
+	if strings.TrimSpace(image) != image {
+		return nil, fmt.Errorf("image reference must not contain whitespace: '%s'", image)
+	}
+
+	ref := &ImageReference{}
+	remainder := image
+	if idx := strings.Index(remainder, "@"); idx != -1 {
+		ref.Digest = remainder[idx+1:]
+		remainder = remainder[:idx]
+		if !imageDigestRegex.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("invalid digest in image reference '%s': %s", image, ref.Digest)
+		}
+		if strings.HasPrefix(ref.Digest, "sha256:") && !sha256DigestRegex.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("invalid sha256 digest in image reference '%s', it must consist of 64 lowercase hex characters", image)
+		}
+	}
+
+	if idx := strings.LastIndex(remainder, ":"); idx > strings.LastIndex(remainder, "/") {
+		ref.Tag = remainder[idx+1:]
+		remainder = remainder[:idx]
+		if !imageTagRegex.MatchString(ref.Tag) {
+			return nil, fmt.Errorf("invalid tag in image reference '%s': %s", image, ref.Tag)
+		}
+	}
+
+	if remainder == "" {
+		return nil, fmt.Errorf("image reference has no name: '%s'", image)
+	}
+	if len(remainder) > maxImageNameLength {
+		return nil, fmt.Errorf("image name must not be longer than %d characters: '%s'", maxImageNameLength, image)
+	}
+
+	ref.Registry = defaultImageRegistry
+	ref.Repository = remainder
+	if idx := strings.Index(remainder, "/"); idx != -1 && isRegistryHost(remainder[:idx]) {
+		ref.Registry = remainder[:idx]
+		ref.Repository = remainder[idx+1:]
+		if !imageDomainRegex.MatchString(ref.Registry) {
+			return nil, fmt.Errorf("invalid registry in image reference '%s': %s", image, ref.Registry)
+		}
+	}
+	if ref.Registry == "index.docker.io" {
+		ref.Registry = defaultImageRegistry
+	}
+
+	for _, component := range strings.Split(ref.Repository, "/") {
+		if !imagePathComponentRegex.MatchString(component) {
+			return nil, fmt.Errorf("invalid repository name in image reference '%s', only lowercase alphanumerics separated by '.', '_', '__' or '-' are allowed: %s", image, ref.Repository)
+		}
+	}
+	if ref.Registry == defaultImageRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = officialImageNamespace + "/" + ref.Repository
+	}
+	return ref, nil
+}
+
+// isRegistryHost tells whether the first path component of an image name is a registry host, which is the case
+// for domains, hosts with ports and 'localhost'. Otherwise, the component is a namespace on Docker Hub.
+func isRegistryHost(component string) bool {
+	return strings.ContainsAny(component, ".:[") || component == "localhost" || strings.ToLower(component) != component
+}
+
+// Name returns the fully qualified image name without tag and digest.
+func (r *ImageReference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+func (r *ImageReference) String() string {
+	result := r.Name()
+	if r.Tag != "" {
+		result += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		result += "@" + r.Digest
+	}
+	return result
+}
+
+// CommonMutableImageTags are tags which are usually moved to newer images over time. They can be added to
+// ImagePolicy.ForbiddenTags to enforce reproducible apps.
+var CommonMutableImageTags = []string{"latest", "stable", "main", "master", "edge", "nightly", "dev", "develop", "next", "beta", "alpha"}
+
+// ImagePolicy defines the supply-chain rules that images of app services must comply with.
+type ImagePolicy struct {
+	// RequireDigest demands every image to be pinned by a digest, like 'gitea/gitea:1.20.2@sha256:...'.
+	RequireDigest bool `yaml:"require_digest"`
+	// AllowedRegistries restricts the registries images may be pulled from, like 'docker.io' or 'ghcr.io'. An empty list allows all registries.
+	AllowedRegistries []string `yaml:"allowed_registries"`
+	// ForbiddenTags are mutable tags which must not be used. The 'latest' tag is always forbidden.
+	ForbiddenTags []string `yaml:"forbidden_tags"`
+}
+
+func DefaultImagePolicy() ImagePolicy {
+	return ImagePolicy{}
+}
+
+func (p ImagePolicy) check(ref *ImageReference, image string) error {
+	if ref.Tag == "" && ref.Digest == "" {
+		return fmt.Errorf(notAllowedMissingDockerImageTag, image)
+	}
+	if ref.Tag == "latest" {
+		return errors.New(notAllowedLatestDockerImageTag)
+	}
+	for _, forbiddenTag := range p.ForbiddenTags {
+		if ref.Tag == forbiddenTag {
+			return fmt.Errorf(notAllowedMutableDockerImageTag, ref.Tag, image)
+		}
+	}
+	if p.RequireDigest && ref.Digest == "" {
+		return fmt.Errorf(imageDigestRequired, image)
+	}
+	if len(p.AllowedRegistries) > 0 && !p.isAllowedRegistry(ref.Registry) {
+		return fmt.Errorf(notAllowedImageRegistry, ref.Registry, image, strings.Join(p.AllowedRegistries, ", "))
+	}
+	return nil
+}
+
+func (p ImagePolicy) isAllowedRegistry(registry string) bool {
+	for _, allowedRegistry := range p.AllowedRegistries {
+		if allowedRegistry == "index.docker.io" {
+			allowedRegistry = defaultImageRegistry
+		}
+		if strings.EqualFold(allowedRegistry, registry) {
+			return true
+		}
+	}
+	return false
+}
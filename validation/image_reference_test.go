@@ -0,0 +1,134 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/ocelot-cloud/shared/assert"
+	"github.com/ocelot-cloud/shared/compose"
+	"strings"
+	"testing"
+)
+
+const sampleDigest = "sha256:8b5a98abe52dacbead16eb4179c9abb7c217d523df3a75901343906ae7853357"
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		image              string
+		expectedRegistry   string
+		expectedRepository string
+		expectedTag        string
+		expectedDigest     string
+	}{
+		{"nginx", "docker.io", "library/nginx", "", ""},
+		{"nginx:1.25", "docker.io", "library/nginx", "1.25", ""},
+		{"gitea/gitea:1.20.2", "docker.io", "gitea/gitea", "1.20.2", ""},
+		{"index.docker.io/gitea/gitea:1.20.2", "docker.io", "gitea/gitea", "1.20.2", ""},
+		{"registry:5000/org/app:1.2", "registry:5000", "org/app", "1.2", ""},
+		{"sample:80/sample:1.0", "sample:80", "sample", "1.0", ""},
+		{"localhost/app:1.0", "localhost", "app", "1.0", ""},
+		{"[::1]:5000/app:1.0", "[::1]:5000", "app", "1.0", ""},
+		{"app:1.0@" + sampleDigest, "docker.io", "library/app", "1.0", sampleDigest},
+		{"ghcr.io/org/team/my_app:v1.0-rc.1@" + sampleDigest, "ghcr.io", "org/team/my_app", "v1.0-rc.1", sampleDigest},
+		{"registry:5000/org/app@" + sampleDigest, "registry:5000", "org/app", "", sampleDigest},
+	}
+
+	for _, test := range tests {
+		ref, err := ParseImageReference(test.image)
+		assert.Nil(t, err, test.image)
+		assert.Equal(t, test.expectedRegistry, ref.Registry, test.image)
+		assert.Equal(t, test.expectedRepository, ref.Repository, test.image)
+		assert.Equal(t, test.expectedTag, ref.Tag, test.image)
+		assert.Equal(t, test.expectedDigest, ref.Digest, test.image)
+	}
+}
+
+func TestParseInvalidImageReference(t *testing.T) {
+	invalidImages := []string{
+		"",
+		" nginx:1.0",
+		":1.0",
+		"Nginx:1.0",
+		"org/App:1.0",
+		"nginx:-1.0",
+		"nginx:1.0@sha256:abc",
+		"nginx:1.0@sha256:8B5A98ABE52DACBEAD16EB4179C9ABB7C217D523DF3A75901343906AE7853357",
+		"nginx:1.0@" + sampleDigest + "@" + sampleDigest,
+		"-registry.com/app:1.0",
+		"org//app:1.0",
+		"org/app_:1.0",
+		strings.Repeat("a", 256) + ":1.0",
+	}
+
+	for _, image := range invalidImages {
+		_, err := ParseImageReference(image)
+		assert.NotNil(t, err, image)
+	}
+}
+
+func TestImageReferenceString(t *testing.T) {
+	ref, err := ParseImageReference("nginx:1.25@" + sampleDigest)
+	assert.Nil(t, err)
+	assert.Equal(t, "docker.io/library/nginx", ref.Name())
+	assert.Equal(t, "docker.io/library/nginx:1.25@"+sampleDigest, ref.String())
+}
+
+func TestImagePolicy(t *testing.T) {
+	strictPolicy := ImagePolicy{
+		RequireDigest:     true,
+		AllowedRegistries: []string{"index.docker.io", "ghcr.io"},
+		ForbiddenTags:     CommonMutableImageTags,
+	}
+
+	testCases := []struct {
+		image         string
+		policy        ImagePolicy
+		expectedError string
+	}{
+		{"gitea/gitea:1.20.2", DefaultImagePolicy(), ""},
+		{"registry:5000/org/app:1.2", DefaultImagePolicy(), ""},
+		{"app@" + sampleDigest, DefaultImagePolicy(), ""},
+		{"gitea/gitea:stable", DefaultImagePolicy(), ""},
+		{"gitea/gitea", DefaultImagePolicy(), fmt.Sprintf(notAllowedMissingDockerImageTag, "gitea/gitea")},
+		{"gitea/gitea:latest", DefaultImagePolicy(), notAllowedLatestDockerImageTag},
+		{"registry:5000/gitea:latest", DefaultImagePolicy(), notAllowedLatestDockerImageTag},
+
+		{"gitea/gitea:1.20.2@" + sampleDigest, strictPolicy, ""},
+		{"ghcr.io/org/app:1.0@" + sampleDigest, strictPolicy, ""},
+		{"gitea/gitea:1.20.2", strictPolicy, fmt.Sprintf(imageDigestRequired, "gitea/gitea:1.20.2")},
+		{"gitea/gitea:stable@" + sampleDigest, strictPolicy, fmt.Sprintf(notAllowedMutableDockerImageTag, "stable", "gitea/gitea:stable@"+sampleDigest)},
+		{"quay.io/org/app:1.0@" + sampleDigest, strictPolicy, fmt.Sprintf(notAllowedImageRegistry, "quay.io", "quay.io/org/app:1.0@"+sampleDigest, "index.docker.io, ghcr.io")},
+	}
+
+	for _, tc := range testCases {
+		err := validateImage("gitea", &compose.Service{Image: tc.image}, tc.policy)
+		if tc.expectedError == "" {
+			assert.Nil(t, err, tc.image)
+		} else {
+			assert.NotNil(t, err, tc.image)
+			assert.Equal(t, tc.expectedError, err.Error())
+		}
+	}
+}
+
+func TestValidateImageWithInvalidValues(t *testing.T) {
+	err := validateImage("gitea", &compose.Service{}, DefaultImagePolicy())
+	assert.NotNil(t, err)
+	assert.Equal(t, "service 'gitea' must have 'image' keyword", err.Error())
+
+	err = validateImage("gitea", &compose.Service{Image: "Gitea:1.0"}, DefaultImagePolicy())
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "invalid image in service 'gitea': "), err.Error())
+}
+
+func TestValidateVersionWithImagePolicy(t *testing.T) {
+	zipBytes, err := createZipWithComposeFile(getSamplesComposeDir(), "sample-gitea.yml")
+	assert.Nil(t, err)
+	options := DefaultValidationOptions()
+	options.Policy.RequireDigest = true
+	err = ValidateVersionWithOptions(zipBytes, maintainerName, appName, options)
+	assert.NotNil(t, err)
+	expectedErrors := []string{
+		fmt.Sprintf(imageDigestRequired, "gitea/gitea:1.20.2"),
+		fmt.Sprintf(imageDigestRequired, "mariadb:10.5"),
+	}
+	assert.Equal(t, strings.Join(expectedErrors, "\n"), err.Error())
+}
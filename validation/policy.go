@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	invalidPolicy                 = "invalid policy: %v"
+	unknownRuleInPolicy           = "unknown rule '%s' in policy, allowed are: %s"
+	invalidReservedPortInPolicy   = "invalid reserved port in policy: %d"
+	capabilityNotAllowedByPolicy  = "capability '%s' requested in app.yml is not allowed, allowed are: %s"
+	noCapabilitiesAllowedByPolicy = "capability '%s' requested in app.yml is not allowed, the policy does not allow requesting capabilities"
+)
+
+// configurableRules are the rules of the docker-compose.yml and app.yml checks which can be switched off by a policy.
+// Rules about the archive, the syntax and the compose specification always apply.
+var configurableRules = []string{
+	RuleTopLevelKey, RuleServiceKey, RuleImage, RuleContainerName, RuleMainService, RuleReservedPort, RuleVolumeMount,
+	RuleHostNetwork, RuleDeploy, RuleDeviceReservation, RuleGlobalVolume, RuleComposeConsistency, RuleVariable,
+	RuleHardcodedCredential, RuleHealthcheck, RuleEnvironment, RuleCapability,
+}
+
+// Policy defines which apps are accepted by the store. Deployments of the store can load their own policy from YAML
+// with LoadPolicy, for example to allow 'devices' for GPUs in a private store.
+type Policy struct {
+	AllowedTopLevelKeys []string `yaml:"allowed_top_level_keys"`
+	AllowedServiceKeys  []string `yaml:"allowed_service_keys"`
+	// ReservedPorts are used by Ocelot-Cloud itself and must not be published by apps.
+	ReservedPorts []int `yaml:"reserved_ports"`
+	// AllowedCapabilities lists the capabilities apps may request in app.yml, an empty list allows none. It should
+	// match HardeningProfile.RequestableCapabilities, which decides what is granted when completing the
+	// docker-compose.yml, so that accepted versions can be deployed.
+	AllowedCapabilities []string `yaml:"allowed_capabilities"`
+	// ImagePolicy holds the supply-chain rules for images, its keys like 'allowed_registries' are given at the top
+	// level of the YAML.
+	ImagePolicy `yaml:",inline"`
+	// Rules switches rules on or off by their ID, like 'device-reservation: false'. Rules which are not listed are on.
+	Rules map[string]bool `yaml:"rules"`
+}
+
+// DefaultPolicy returns the policy of the public store.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		AllowedTopLevelKeys: []string{"services", "volumes"},
+		AllowedServiceKeys:  []string{"image", "container_name", "ports", "volumes", "depends_on", "environment", "deploy", "tmpfs", "tty", "user", "command", "entrypoint", "healthcheck"},
+		ReservedPorts:       []int{53, 80, 443},
+		ImagePolicy:         DefaultImagePolicy(),
+	}
+}
+
+// LoadPolicy reads a policy from a YAML file. Keys which are not given keep the values of the DefaultPolicy.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path) // #nosec G304 (CWE-22): Potential file inclusion via variable; the path is configured by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %v", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses a policy like LoadPolicy. Unknown keys and rules are rejected, so that typos do not silently
+// weaken the policy.
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := DefaultPolicy()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf(invalidPolicy, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (p *Policy) validate() error {
+	for _, rule := range sortedKeys(p.Rules) {
+		if !contains(configurableRules, rule) {
+			return fmt.Errorf(unknownRuleInPolicy, rule, strings.Join(configurableRules, ", "))
+		}
+	}
+	for _, port := range p.ReservedPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf(invalidReservedPortInPolicy, port)
+		}
+	}
+	return nil
+}
+
+// IsRuleEnabled tells whether findings of the rule are reported.
+func (p *Policy) IsRuleEnabled(ruleID string) bool {
+	enabled, ok := p.Rules[ruleID]
+	return !ok || enabled
+}
+
+// validateCapabilityRequests checks the capabilities requested in app.yml against the allowlist of the policy.
+func validateCapabilityRequests(r *report, manifest *AppManifest, policy *Policy) {
+	if manifest == nil || !policy.IsRuleEnabled(RuleCapability) {
+		return
+	}
+	for _, request := range manifest.Capabilities {
+		if len(policy.AllowedCapabilities) == 0 {
+			r.addError(RuleCapability, appYamlFileName, fmt.Errorf(noCapabilitiesAllowedByPolicy, request.Name))
+		} else if !contains(policy.AllowedCapabilities, request.Name) {
+			r.addError(RuleCapability, appYamlFileName, fmt.Errorf(capabilityNotAllowedByPolicy, request.Name, strings.Join(policy.AllowedCapabilities, ", ")))
+		}
+	}
+}
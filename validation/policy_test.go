@@ -0,0 +1,110 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/ocelot-cloud/shared/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func loadPrivateStorePolicy(t *testing.T) *Policy {
+	policy, err := LoadPolicy(getSamplesDir() + "/policies/private-store.yml")
+	assert.Nil(t, err)
+	return policy
+}
+
+func TestLoadPolicy(t *testing.T) {
+	policy := loadPrivateStorePolicy(t)
+	assert.Equal(t, DefaultPolicy().AllowedTopLevelKeys, policy.AllowedTopLevelKeys)
+	assert.Equal(t, []int{53, 80, 443, 8080}, policy.ReservedPorts)
+	assert.True(t, contains(policy.AllowedServiceKeys, "devices"))
+	assert.False(t, policy.IsRuleEnabled(RuleDeviceReservation))
+	assert.True(t, policy.IsRuleEnabled(RuleReservedPort))
+	assert.Equal(t, []string{"docker.io"}, policy.AllowedRegistries)
+
+	policy, err := ParsePolicy([]byte(""))
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultPolicy(), policy)
+
+	_, err = LoadPolicy(getSamplesDir() + "/policies/not-existing.yml")
+	assert.NotNil(t, err)
+}
+
+func TestParseInvalidPolicy(t *testing.T) {
+	testCases := []struct {
+		content       string
+		expectedError string
+	}{
+		{"reserved_port: [80]", "invalid policy: yaml: unmarshal errors:\n  line 1: field reserved_port not found in type validation.Policy"},
+		{"reserved_ports: [0]", fmt.Sprintf(invalidReservedPortInPolicy, 0)},
+		{"rules: {compose-schema: false}", "unknown rule 'compose-schema' in policy, allowed are: top-level-key, service-key, image, container-name, main-service, reserved-port, volume-mount, host-network, deploy, device-reservation, global-volume, compose-consistency, variable, hardcoded-credential, healthcheck, environment, capability"},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParsePolicy([]byte(tc.content))
+		assert.NotNil(t, err, tc.content)
+		if err != nil {
+			assert.Equal(t, tc.expectedError, err.Error())
+		}
+	}
+}
+
+func TestValidateVersionWithPolicy(t *testing.T) {
+	options := DefaultValidationOptions()
+	options.Policy = loadPrivateStorePolicy(t)
+	testCases := []struct {
+		file          string
+		expectedError string
+	}{
+		{"devices-keyword-in-resources.yml", ""},
+		{"using-restart.yml", ""},
+		{"hardcoded-password.yml", ""},
+		{"using-cap-drop.yml", fmt.Sprintf(notAllowedKeyInService, "gitea", "cap_drop")},
+		{"using-healthcheck.yml", fmt.Sprintf(notAllowedKeyInService, "gitea", "healthcheck")},
+		{"exposing-port-80.yml", fmt.Sprintf(notAllowedExposingDefaultHttpPorts, "80")},
+	}
+
+	for _, tc := range testCases {
+		zipBytes, err := createZipWithComposeFile(getSamplesComposeDir(), tc.file)
+		assert.Nil(t, err)
+		err = ValidateVersionWithOptions(zipBytes, maintainerName, appName, options)
+		if tc.expectedError == "" {
+			assert.Nil(t, err, tc.file)
+		} else {
+			assert.NotNil(t, err, tc.file)
+			if err != nil {
+				assert.Equal(t, tc.expectedError, err.Error())
+			}
+		}
+	}
+}
+
+func TestPolicyRestrictsCapabilityRequests(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, copyFile(getSamplesComposeDir()+"/sample-gitea.yml", filepath.Join(dir, composeFileName)))
+	appYaml := "capabilities:\n  - name: CAP_SYS_ADMIN\n    justification: mounts file systems\n"
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, appYamlFileName), []byte(appYaml), 0600))
+	zipBytes, err := ZipDirectory(dir)
+	assert.Nil(t, err)
+	err = ValidateVersion(zipBytes, maintainerName, appName)
+	assert.NotNil(t, err)
+	if err != nil {
+		assert.Equal(t, fmt.Sprintf(noCapabilitiesAllowedByPolicy, "CAP_SYS_ADMIN"), err.Error())
+	}
+
+	options := DefaultValidationOptions()
+	options.Policy = DefaultPolicy()
+	options.Policy.AllowedCapabilities = []string{"CAP_NET_RAW"}
+	findings := DiagnoseVersion(zipBytes, maintainerName, appName, options)
+	assert.Equal(t, []Finding{{RuleID: RuleCapability, Severity: SeverityError, File: appYamlFileName, Message: fmt.Sprintf(capabilityNotAllowedByPolicy, "CAP_SYS_ADMIN", "CAP_NET_RAW")}}, findings)
+
+	options.Policy.AllowedCapabilities = []string{"CAP_SYS_ADMIN"}
+	assert.Nil(t, ValidateVersionWithOptions(zipBytes, maintainerName, appName, options))
+}
+
+// TestDefaultPolicyMatchesDefaultHardeningProfile checks that versions accepted with the default policy can be
+// completed with the default hardening profile.
+func TestDefaultPolicyMatchesDefaultHardeningProfile(t *testing.T) {
+	assert.Equal(t, DefaultHardeningProfile().RequestableCapabilities, DefaultPolicy().AllowedCapabilities)
+}
@@ -0,0 +1,84 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/ocelot-cloud/shared/compose"
+	"strconv"
+)
+
+const (
+	defaultAppPort    = 80
+	defaultUrlPath    = "/"
+	traefikEntrypoint = "websecure"
+)
+
+// RoutingConfig describes how the reverse proxy forwards requests to the main service of an app. It is derived from
+// the app.yml, so that the cloud and the proxy labels written by CompleteDockerComposeYaml always agree.
+type RoutingConfig struct {
+	// Name identifies the router and the load balancer of the app in the proxy.
+	Name string
+	// Host is the domain under which the app is reachable.
+	Host string
+	// UrlPath is the path the browser is sent to when the app is opened.
+	UrlPath string
+	// Service is the name of the main service in the docker-compose.yml, which receives the requests.
+	Service       string
+	ContainerName string
+	Port          int
+	Network       string
+}
+
+// NewRoutingConfig creates the routing config of an app. The manifest may be nil, in which case port 80 and url path
+// "/" are used.
+func NewRoutingConfig(maintainer, appName, host string, manifest *AppManifest) RoutingConfig {
+	config := RoutingConfig{
+		Name:          fmt.Sprintf("%s_%s", maintainer, appName),
+		Host:          host,
+		UrlPath:       defaultUrlPath,
+		Service:       appName,
+		ContainerName: fmt.Sprintf("%s_%s_%s", maintainer, appName, appName),
+		Port:          defaultAppPort,
+		Network:       fmt.Sprintf("%s_%s", maintainer, appName),
+	}
+	if manifest != nil && manifest.UrlPath != "" {
+		config.UrlPath = manifest.UrlPath
+	}
+	if manifest != nil && manifest.Port != 0 {
+		config.Port = manifest.Port
+	}
+	return config
+}
+
+// URL returns the address the user opens to use the app.
+func (c RoutingConfig) URL() string {
+	return "https://" + c.Host + c.UrlPath
+}
+
+// TraefikLabels returns the docker labels which make Traefik forward the requests for the host to the main service.
+func (c RoutingConfig) TraefikLabels() map[string]string {
+	router := "traefik.http.routers." + c.Name
+	service := "traefik.http.services." + c.Name
+	return map[string]string{
+		"traefik.enable":                      "true",
+		"traefik.docker.network":              c.Network,
+		router + ".rule":                      fmt.Sprintf("Host(`%s`)", c.Host),
+		router + ".entrypoints":               traefikEntrypoint,
+		router + ".tls":                       "true",
+		router + ".service":                   c.Name,
+		service + ".loadbalancer.server.port": strconv.Itoa(c.Port),
+	}
+}
+
+// addRoutingLabels adds the proxy labels to the main service, if it exists.
+func addRoutingLabels(project *compose.Project, config RoutingConfig) {
+	service, ok := project.Services[config.Service]
+	if !ok {
+		return
+	}
+	if service.Labels == nil {
+		service.Labels = compose.Labels{}
+	}
+	for key, value := range config.TraefikLabels() {
+		service.Labels[key] = value
+	}
+}
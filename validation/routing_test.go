@@ -0,0 +1,40 @@
+package validation
+
+import (
+	"github.com/ocelot-cloud/shared/assert"
+	"os"
+	"testing"
+)
+
+func TestNewRoutingConfig(t *testing.T) {
+	config := NewRoutingConfig("samplemaintainer", "gitea", "my-domain.com", nil)
+	assert.Equal(t, RoutingConfig{
+		Name:          "samplemaintainer_gitea",
+		Host:          "my-domain.com",
+		UrlPath:       "/",
+		Service:       "gitea",
+		ContainerName: "samplemaintainer_gitea_gitea",
+		Port:          80,
+		Network:       "samplemaintainer_gitea",
+	}, config)
+	assert.Equal(t, "https://my-domain.com/", config.URL())
+
+	config = NewRoutingConfig("samplemaintainer", "gitea", "my-domain.com", &AppManifest{UrlPath: "/explore", Port: 3000})
+	assert.Equal(t, "/explore", config.UrlPath)
+	assert.Equal(t, 3000, config.Port)
+	assert.Equal(t, "https://my-domain.com/explore", config.URL())
+	assert.Equal(t, "3000", config.TraefikLabels()["traefik.http.services.samplemaintainer_gitea.loadbalancer.server.port"])
+	assert.Equal(t, "Host(`my-domain.com`)", config.TraefikLabels()["traefik.http.routers.samplemaintainer_gitea.rule"])
+}
+
+func TestCompleteDockerComposeYamlWithAppYaml(t *testing.T) {
+	appYaml, err := os.ReadFile(getSamplesDir() + "/yaml-keyword-completion/app.yml")
+	assert.Nil(t, err)
+	composePath, err := completeSampleWithProfile(t, string(appYaml), DefaultHardeningProfile())
+	assert.Nil(t, err)
+	expectedBytes, err := os.ReadFile(getSamplesDir() + "/yaml-keyword-completion/expected-output-with-app-yml.yml")
+	assert.Nil(t, err)
+	actualBytes, err := os.ReadFile(composePath)
+	assert.Nil(t, err)
+	AssertYamlEquality(t, expectedBytes, actualBytes)
+}
@@ -0,0 +1,231 @@
+package validation
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/ocelot-cloud/shared/compose"
+	"gopkg.in/yaml.v3"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	invalidSecretNameInAppYaml    = "invalid secret name in app.yml, it must consist of uppercase letters, digits and underscores: %s"
+	invalidSecretLengthInAppYaml  = "invalid length of secret '%s' in app.yml, it must be between %d and %d"
+	invalidSecretCharsetInAppYaml = "invalid charset of secret '%s' in app.yml, allowed are: %s"
+	duplicateSecretInAppYaml      = "duplicate secret in app.yml: %s"
+	missingSecretValue            = "no value given for secret '%s' declared in app.yml, generate the secrets of the installation with GenerateSecrets"
+	unresolvedSecretReference     = "no value given for secret variable '%s' referenced in docker-compose.yml"
+	hardcodedCredential           = "environment variable '%s' of service '%s' looks like a hard-coded credential, declare a secret in app.yml and use ${SECRET_<name>} instead"
+)
+
+const (
+	defaultSecretLength  = 32
+	minSecretLength      = 16
+	maxSecretLength      = 128
+	defaultSecretCharset = "alphanumeric"
+)
+
+const (
+	lowercaseLetters = "abcdefghijklmnopqrstuvwxyz"
+	uppercaseLetters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits           = "0123456789"
+)
+
+// secretCharsets are the characters secrets are generated from. Characters with a special meaning in compose files,
+// shells or connection URLs, like '$', quotes and '&', are left out on purpose.
+var secretCharsets = map[string]string{
+	"alphanumeric":           lowercaseLetters + uppercaseLetters + digits,
+	"lowercase-alphanumeric": lowercaseLetters + digits,
+	"hex":                    digits + "abcdef",
+	"symbols":                lowercaseLetters + uppercaseLetters + digits + "!#%+,-.:=?@^_~",
+}
+
+var (
+	secretNameRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,63}$`)
+	// credentialNameRegex matches names of environment variables which usually hold credentials.
+	credentialNameRegex = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|PASS$|SECRET|TOKEN|API_?KEY|PRIVATE_?KEY|CREDENTIALS?$)`)
+	// nonCredentialSuffixRegex matches variables which only configure how credentials are handled, like PASSWORD_FILE.
+	nonCredentialSuffixRegex = regexp.MustCompile(`(?i)_(FILE|PATH|LENGTH|MIN_LENGTH|ENABLED|DISABLED|REQUIRED|ALGORITHM|HASH_ALGO|COMPLEXITY)$`)
+	// knownSecretFormatRegex matches values in the formats of private keys and of tokens of well-known services.
+	knownSecretFormatRegex = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----|\bAKIA[0-9A-Z]{16}\b|\bgh[pousr]_[A-Za-z0-9]{36,}|\bgithub_pat_[A-Za-z0-9_]{22,}|\bglpat-[A-Za-z0-9_-]{20,}|\bxox[abposr]-[A-Za-z0-9-]{10,}|\b[rs]k_live_[A-Za-z0-9]{20,}|\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)
+)
+
+const (
+	// minHighEntropyLength and minHighEntropyBits define values which are random enough to be generated secrets.
+	minHighEntropyLength = 16
+	minHighEntropyBits   = 3.5
+)
+
+// SecretDeclaration is a secret in app.yml which is generated once per installation and referenced in the
+// docker-compose.yml as ${SECRET_<name>}.
+type SecretDeclaration struct {
+	Name    string `yaml:"name"`
+	Length  int    `yaml:"length,omitempty"`
+	Charset string `yaml:"charset,omitempty"`
+}
+
+func decodeSecretDeclaration(node *yaml.Node) (SecretDeclaration, error) {
+	secret := SecretDeclaration{Length: defaultSecretLength, Charset: defaultSecretCharset}
+	if node.Kind != yaml.MappingNode {
+		return secret, fmt.Errorf(wrongTypeInAppYaml, "secrets", "a list of maps with 'name', 'length' and 'charset'")
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		var err error
+		switch key {
+		case "name":
+			err = decodeManifestString(value, "secrets.name", &secret.Name)
+		case "length":
+			if value.Kind != yaml.ScalarNode || value.ShortTag() != "!!int" {
+				err = fmt.Errorf(wrongTypeInAppYaml, "secrets.length", "a number")
+			} else {
+				secret.Length, _ = strconv.Atoi(value.Value)
+			}
+		case "charset":
+			err = decodeManifestString(value, "secrets.charset", &secret.Charset)
+		default:
+			err = fmt.Errorf(notAllowedKeyInAppYaml, "secrets."+key)
+		}
+		if err != nil {
+			return secret, err
+		}
+	}
+	if !secretNameRegex.MatchString(secret.Name) {
+		return secret, fmt.Errorf(invalidSecretNameInAppYaml, secret.Name)
+	}
+	if secret.Length < minSecretLength || secret.Length > maxSecretLength {
+		return secret, fmt.Errorf(invalidSecretLengthInAppYaml, secret.Name, minSecretLength, maxSecretLength)
+	}
+	if _, ok := secretCharsets[secret.Charset]; !ok {
+		return secret, fmt.Errorf(invalidSecretCharsetInAppYaml, secret.Name, strings.Join(sortedKeys(secretCharsets), ", "))
+	}
+	return secret, nil
+}
+
+// GenerateSecrets returns the values of all secrets declared in the manifest. Values of existing secrets are kept, so
+// that the result can be persisted and passed again on every update of the installation. Values of secrets which are
+// no longer declared are dropped.
+func GenerateSecrets(manifest *AppManifest, existing map[string]string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	if manifest == nil {
+		return secrets, nil
+	}
+	for _, declaration := range manifest.Secrets {
+		if value, ok := existing[declaration.Name]; ok {
+			secrets[declaration.Name] = value
+			continue
+		}
+		value, err := generateSecret(declaration.Length, secretCharsets[declaration.Charset])
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate secret %s: %v", declaration.Name, err)
+		}
+		secrets[declaration.Name] = value
+	}
+	return secrets, nil
+}
+
+func generateSecret(length int, charset string) (string, error) {
+	if length <= 0 || charset == "" {
+		return "", fmt.Errorf("invalid secret length %d or empty charset", length)
+	}
+	charsetSize := big.NewInt(int64(len(charset)))
+	secret := make([]byte, length)
+	for i := range secret {
+		index, err := rand.Int(rand.Reader, charsetSize)
+		if err != nil {
+			return "", err
+		}
+		secret[i] = charset[index.Int64()]
+	}
+	return string(secret), nil
+}
+
+// checkSecretValues checks that the installation has a value for every secret declared in the manifest, since
+// compose would otherwise fill the reference from the environment of the host or leave it empty.
+func checkSecretValues(manifest *AppManifest, secrets map[string]string) error {
+	if manifest == nil {
+		return nil
+	}
+	for _, declaration := range manifest.Secrets {
+		if _, ok := secrets[declaration.Name]; !ok {
+			return fmt.Errorf(missingSecretValue, declaration.Name)
+		}
+	}
+	return nil
+}
+
+// isDeclaredSecret tells whether the variable references a secret declared in app.yml, like ${SECRET_DB_PASSWORD}.
+func isDeclaredSecret(manifest *AppManifest, variableName string) bool {
+	if manifest == nil || !strings.HasPrefix(variableName, SecretVariablePrefix) {
+		return false
+	}
+	name := strings.TrimPrefix(variableName, SecretVariablePrefix)
+	for _, secret := range manifest.Secrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCredentials flags environment variables whose name suggests a credential but whose value is a literal
+// instead of a reference to a generated secret.
+func (v *composeValidator) validateCredentials(serviceName string, service *compose.Service) {
+	for i, variable := range service.Environment.Variables {
+		if variable.Value == nil {
+			continue
+		}
+		severity, found := hardcodedCredentialSeverity(variable.Name, *variable.Value)
+		if !found {
+			continue
+		}
+		v.addFinding(RuleHardcodedCredential, severity, serviceName, v.servicePosition(serviceName, "environment", environmentEntry(service, i)), hardcodedCredential, variable.Name, serviceName)
+	}
+}
+
+// hardcodedCredentialSeverity tells whether the value of the environment variable is a hard-coded credential. Values
+// in a known secret format and random values of variables named like credentials are errors. Other values of
+// variables named like credentials are only warnings, since names like TOKEN_EXPIRY do not always hold credentials.
+func hardcodedCredentialSeverity(name, value string) (Severity, bool) {
+	references, err := compose.ReferencedVariables(value)
+	if err != nil || len(references) > 0 {
+		return "", false
+	}
+	if knownSecretFormatRegex.MatchString(value) {
+		return SeverityError, true
+	}
+	if !credentialNameRegex.MatchString(name) || nonCredentialSuffixRegex.MatchString(name) {
+		return "", false
+	}
+	switch strings.ToLower(value) {
+	case "", "true", "false", "yes", "no", "on", "off":
+		return "", false
+	}
+	if isHighEntropy(value) {
+		return SeverityError, true
+	}
+	return SeverityWarning, true
+}
+
+// isHighEntropy tells whether the value is long and its characters are as diverse as in generated secrets.
+func isHighEntropy(value string) bool {
+	length := float64(utf8.RuneCountInString(value))
+	if length < minHighEntropyLength {
+		return false
+	}
+	counts := make(map[rune]int)
+	for _, char := range value {
+		counts[char]++
+	}
+	var bits float64
+	for _, count := range counts {
+		probability := float64(count) / length
+		bits -= probability * math.Log2(probability)
+	}
+	return bits >= minHighEntropyBits
+}
@@ -0,0 +1,118 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Svg icons are displayed in the browser, where an svg document can run scripts through script elements, event
+// handler attributes, javascript urls or embedded html. So only the elements and attributes needed to draw static
+// images are allowed, and references must point to elements of the icon itself.
+
+const (
+	svgNamespace   = "http://www.w3.org/2000/svg"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+)
+
+var allowedSvgElements = []string{
+	"svg", "g", "defs", "title", "desc", "symbol", "use",
+	"path", "rect", "circle", "ellipse", "line", "polyline", "polygon",
+	"text", "tspan",
+	"linearGradient", "radialGradient", "stop", "pattern", "clipPath", "mask",
+}
+
+var allowedSvgAttributes = []string{
+	"id", "class", "version", "baseProfile", "viewBox", "preserveAspectRatio", "transform",
+	"x", "y", "x1", "y1", "x2", "y2", "cx", "cy", "r", "rx", "ry", "fx", "fy", "dx", "dy", "width", "height",
+	"d", "points", "offset", "href",
+	"fill", "fill-opacity", "fill-rule", "stroke", "stroke-width", "stroke-opacity", "stroke-linecap",
+	"stroke-linejoin", "stroke-miterlimit", "stroke-dasharray", "stroke-dashoffset", "opacity", "color",
+	"stop-color", "stop-opacity", "clip-path", "clip-rule", "mask", "visibility", "display",
+	"gradientUnits", "gradientTransform", "spreadMethod", "patternUnits", "patternContentUnits", "patternTransform",
+	"clipPathUnits", "maskUnits", "maskContentUnits",
+	"font-family", "font-size", "font-style", "font-weight", "text-anchor", "dominant-baseline", "letter-spacing",
+}
+
+// validateSvgIcon checks that the svg only consists of allowed elements and attributes.
+func validateSvgIcon(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	hasRoot := false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid xml: %v", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if !hasRoot && t.Name.Local != "svg" {
+				return fmt.Errorf("the root element must be 'svg', but is '%s'", t.Name.Local)
+			}
+			hasRoot = true
+			if t.Name.Space != svgNamespace {
+				return fmt.Errorf("element '%s' is not in the svg namespace %s", t.Name.Local, svgNamespace)
+			}
+			if !contains(allowedSvgElements, t.Name.Local) {
+				return fmt.Errorf("element '%s' is not allowed", t.Name.Local)
+			}
+			for _, attribute := range t.Attr {
+				if err := validateSvgAttribute(attribute); err != nil {
+					return err
+				}
+			}
+		case xml.Directive:
+			return errors.New("document type declarations are not allowed")
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				return fmt.Errorf("processing instruction '%s' is not allowed", t.Target)
+			}
+		}
+	}
+	if !hasRoot {
+		return errors.New("the root element must be 'svg'")
+	}
+	return nil
+}
+
+func validateSvgAttribute(attribute xml.Attr) error {
+	name := attribute.Name
+	if name.Space == "xmlns" || (name.Space == "" && name.Local == "xmlns") {
+		return nil
+	}
+	allowed := name.Space == "" && contains(allowedSvgAttributes, name.Local)
+	if name.Space == xlinkNamespace && name.Local == "href" {
+		allowed = true
+	}
+	if !allowed {
+		return fmt.Errorf("attribute '%s' is not allowed", name.Local)
+	}
+	if name.Local == "href" && !strings.HasPrefix(attribute.Value, "#") {
+		return fmt.Errorf("reference '%s' is not allowed, only references to elements of the icon like '#id' are", attribute.Value)
+	}
+	if !hasOnlyLocalUrls(attribute.Value) {
+		return fmt.Errorf("value '%s' of attribute '%s' is not allowed, only references to elements of the icon like 'url(#id)' are", attribute.Value, name.Local)
+	}
+	return nil
+}
+
+// hasOnlyLocalUrls tells whether all urls of a presentation attribute like fill="url(#gradient)" point to elements
+// of the icon itself.
+func hasOnlyLocalUrls(value string) bool {
+	value = strings.ToLower(value)
+	for {
+		index := strings.Index(value, "url(")
+		if index == -1 {
+			return true
+		}
+		value = strings.TrimLeft(value[index+len("url("):], " \t\n\r'\"")
+		if !strings.HasPrefix(value, "#") {
+			return false
+		}
+	}
+}
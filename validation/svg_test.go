@@ -0,0 +1,42 @@
+package validation
+
+import (
+	"github.com/ocelot-cloud/shared/assert"
+	"testing"
+)
+
+func TestValidateSvgIcon(t *testing.T) {
+	testCases := []struct {
+		content       string
+		expectedError string
+	}{
+		{`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><circle cx="5" cy="5" r="4" fill="red"/></svg>`, ""},
+		{`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><defs><linearGradient id="g"><stop offset="0" stop-color="#fff"/></linearGradient><path id="p" d="M0 0h1"/></defs><rect width="10" height="10" fill="url(#g)"/><use xlink:href="#p"/><use href="#p"/></svg>`, ""},
+		{`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`, "element 'script' is not allowed"},
+		{`<svg xmlns="http://www.w3.org/2000/svg"><SCRIPT>alert(1)</SCRIPT></svg>`, "element 'SCRIPT' is not allowed"},
+		{`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"></svg>`, "attribute 'onload' is not allowed"},
+		{`<svg xmlns="http://www.w3.org/2000/svg"><rect ONCLICK="alert(1)"/></svg>`, "attribute 'ONCLICK' is not allowed"},
+		{`<svg xmlns="http://www.w3.org/2000/svg"><foreignObject><body xmlns="http://www.w3.org/1999/xhtml"/></foreignObject></svg>`, "element 'foreignObject' is not allowed"},
+		{`<svg xmlns="http://www.w3.org/2000/svg"><use href="data:image/svg+xml;base64,PHN2Zz4="/></svg>`, "reference 'data:image/svg+xml;base64,PHN2Zz4=' is not allowed, only references to elements of the icon like '#id' are"},
+		{`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="https://example.com/evil.svg#a"/></svg>`, "reference 'https://example.com/evil.svg#a' is not allowed, only references to elements of the icon like '#id' are"},
+		{`<svg xmlns="http://www.w3.org/2000/svg"><rect fill="URL(javascript:alert(1))"/></svg>`, "value 'URL(javascript:alert(1))' of attribute 'fill' is not allowed, only references to elements of the icon like 'url(#id)' are"},
+		{`<svg xmlns="http://www.w3.org/2000/svg"><rect style="fill:red"/></svg>`, "attribute 'style' is not allowed"},
+		{`<!DOCTYPE svg [<!ENTITY a "b">]><svg xmlns="http://www.w3.org/2000/svg"></svg>`, "document type declarations are not allowed"},
+		{`<svg><circle r="1"/></svg>`, "element 'svg' is not in the svg namespace http://www.w3.org/2000/svg"},
+		{`<html xmlns="http://www.w3.org/2000/svg"></html>`, "the root element must be 'svg', but is 'html'"},
+		{`not xml`, "the root element must be 'svg'"},
+		{`<svg xmlns="http://www.w3.org/2000/svg">`, "invalid xml: XML syntax error on line 1: unexpected EOF"},
+	}
+
+	for _, tc := range testCases {
+		err := validateSvgIcon([]byte(tc.content))
+		if tc.expectedError == "" {
+			assert.Nil(t, err, tc.content)
+		} else {
+			assert.NotNil(t, err, tc.content)
+			if err != nil {
+				assert.Equal(t, tc.expectedError, err.Error())
+			}
+		}
+	}
+}
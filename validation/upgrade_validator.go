@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ocelot-cloud/shared/compose"
+	"github.com/ocelot-cloud/shared/utils"
+	"sort"
+	"strings"
+)
+
+var (
+	serviceRemovedInUpgrade       = "service '%s' was removed or renamed, which is not allowed in an upgrade"
+	imageRegistryChangedInUpgrade = "image registry of service '%s' changed from '%s' to '%s', which is not allowed in an upgrade"
+	imageNameChangedInUpgrade     = "image name of service '%s' changed from '%s' to '%s', which is not allowed in an upgrade"
+	dataVolumeRemovedInUpgrade    = "volume '%s' of service '%s' holds user data and must not be removed in an upgrade"
+	dataVolumeMovedInUpgrade      = "volume '%s' of service '%s' must stay mounted at '%s' in an upgrade, but is mounted at '%s'"
+)
+
+// ValidateUpgrade checks whether an installed app version can be upgraded to a new version without breaking it
+// or losing user data. Only the image tags and digests may change, services and their data volumes must be kept.
+// All incompatibilities found are reported together in the returned error.
+func ValidateUpgrade(oldZipBytes, newZipBytes []byte, maintainerName, appName string) error {
+	oldCompose, err := readComposeFromZip(oldZipBytes)
+	if err != nil {
+		return fmt.Errorf("failed to read old version: %w", err)
+	}
+	newCompose, err := readComposeFromZip(newZipBytes)
+	if err != nil {
+		return fmt.Errorf("failed to read new version: %w", err)
+	}
+	return compareComposeFiles(oldCompose, newCompose, maintainerName, appName)
+}
+
+func readComposeFromZip(zipBytes []byte) (*compose.Project, error) {
+	fsys, err := utils.OpenZip(zipBytes)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readVersionFile(fsys, composeFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docker-compose.yml: %v", err)
+	}
+	return parseComposeData(data)
+}
+
+func compareComposeFiles(oldProject, newProject *compose.Project, maintainerName, appName string) error {
+	var errs []error
+	for _, serviceName := range oldProject.ServiceNames() {
+		newService, found := newProject.Services[serviceName]
+		if !found {
+			errs = append(errs, fmt.Errorf(serviceRemovedInUpgrade, serviceName))
+			continue
+		}
+		oldService := oldProject.Services[serviceName]
+		errs = append(errs, compareImages(serviceName, oldService, newService)...)
+		errs = append(errs, compareDataVolumes(serviceName, oldService, newService, maintainerName, appName)...)
+	}
+	return errors.Join(errs...)
+}
+
+func compareImages(serviceName string, oldService, newService *compose.Service) []error {
+	oldRef, err := ParseImageReference(oldService.Image)
+	if err != nil {
+		return []error{fmt.Errorf(invalidImageReference, serviceName, err)}
+	}
+	newRef, err := ParseImageReference(newService.Image)
+	if err != nil {
+		return []error{fmt.Errorf(invalidImageReference, serviceName, err)}
+	}
+
+	var errs []error
+	if oldRef.Registry != newRef.Registry {
+		errs = append(errs, fmt.Errorf(imageRegistryChangedInUpgrade, serviceName, oldRef.Registry, newRef.Registry))
+	}
+	if oldRef.Repository != newRef.Repository {
+		errs = append(errs, fmt.Errorf(imageNameChangedInUpgrade, serviceName, oldRef.Repository, newRef.Repository))
+	}
+	return errs
+}
+
+func compareDataVolumes(serviceName string, oldService, newService *compose.Service, maintainerName, appName string) []error {
+	oldMounts := dataVolumeMounts(oldService, maintainerName, appName)
+	newMounts := dataVolumeMounts(newService, maintainerName, appName)
+
+	var errs []error
+	for _, volumeName := range sortedKeys(oldMounts) {
+		newTarget, found := newMounts[volumeName]
+		if !found {
+			errs = append(errs, fmt.Errorf(dataVolumeRemovedInUpgrade, volumeName, serviceName))
+		} else if newTarget != oldMounts[volumeName] {
+			errs = append(errs, fmt.Errorf(dataVolumeMovedInUpgrade, volumeName, serviceName, oldMounts[volumeName], newTarget))
+		}
+	}
+	return errs
+}
+
+// dataVolumeMounts maps the names of the volumes belonging to the app, which are mounted by the service, to their mount targets.
+func dataVolumeMounts(service *compose.Service, maintainerName, appName string) map[string]string {
+	mounts := make(map[string]string)
+	prefix := fmt.Sprintf("%s_%s_", maintainerName, appName)
+	for _, volume := range service.Volumes {
+		mount, err := volume.Mount()
+		if err != nil || mount.Type != compose.MountTypeVolume {
+			continue
+		}
+		if strings.HasPrefix(mount.Source, prefix) {
+			mounts[mount.Source] = mount.Target
+		}
+	}
+	return mounts
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
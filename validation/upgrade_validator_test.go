@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/ocelot-cloud/shared/assert"
+	"strings"
+	"testing"
+)
+
+func TestValidateUpgrade(t *testing.T) {
+	testCases := []struct {
+		oldFile       string
+		newFile       string
+		expectedError string
+	}{
+		{"default-1.0.yml", "default-2.0.yml", ""},
+		{"default-1.0.yml", "default-2.0-with-shasum.yml", ""},
+		{"default-1.0.yml", "duplicate-images.yml", ""},
+		{"registry-port-1.0.yml", "registry-port-2.0.yml", ""},
+		{"two-images.yml", "two-images.yml", ""},
+		{"volume-1.0.yml", "volume-2.0.yml", ""},
+
+		{"default-1.0.yml", "changed-registry.yml", fmt.Sprintf(imageRegistryChangedInUpgrade, "sample", "docker.io", "myregistry.com")},
+		{"default-1.0.yml", "different-image-maintainer.yml", fmt.Sprintf(imageNameChangedInUpgrade, "sample", "sample/sample", "different/sample")},
+		{"default-1.0.yml", "different-image-name.yml", fmt.Sprintf(imageNameChangedInUpgrade, "sample", "sample/sample", "sample/different")},
+		{"registry-port-1.0.yml", "different-registry-port-2.0.yml", fmt.Sprintf(imageRegistryChangedInUpgrade, "sample", "sample:80", "sample:81")},
+		{"two-images.yml", "two-images-swapped.yml", fmt.Sprintf(imageNameChangedInUpgrade, "gitea", "gitea/gitea", "sample/sample")},
+		{"default-1.0.yml", "service-name-changes.yml", fmt.Sprintf(serviceRemovedInUpgrade, "sample")},
+		{"volume-1.0.yml", "volume-removed-2.0.yml", fmt.Sprintf(dataVolumeRemovedInUpgrade, "samplemaintainer_sample_data", "sample")},
+		{"volume-1.0.yml", "volume-moved-2.0.yml", fmt.Sprintf(dataVolumeMovedInUpgrade, "samplemaintainer_sample_data", "sample", "/data", "/var/data")},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.oldFile+"->"+tc.newFile, func(t *testing.T) {
+			err := validateUpgradeOfSamples(tc.oldFile, tc.newFile)
+			if tc.expectedError == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+				assert.True(t, strings.Contains(err.Error(), tc.expectedError), err.Error())
+			}
+		})
+	}
+}
+
+func TestValidateUpgradeReportsAllIncompatibilities(t *testing.T) {
+	err := validateUpgradeOfSamples("two-images.yml", "everything-changed.yml")
+	assert.NotNil(t, err)
+	expectedErrors := []string{
+		fmt.Sprintf(imageRegistryChangedInUpgrade, "sample", "docker.io", "myregistry.com"),
+		fmt.Sprintf(imageNameChangedInUpgrade, "sample", "sample/sample", "other"),
+		fmt.Sprintf(serviceRemovedInUpgrade, "gitea"),
+	}
+	assert.Equal(t, strings.Join(expectedErrors, "\n"), err.Error())
+}
+
+func TestValidateUpgradeWithInvalidZip(t *testing.T) {
+	zipBytes, err := createZipWithComposeFile(getSamplesDir()+"/update-validation-test", "default-1.0.yml")
+	assert.Nil(t, err)
+	err = ValidateUpgrade([]byte("hello"), zipBytes, maintainerName, "sample")
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to read old version: failed to read zip file: zip: not a valid zip file", err.Error())
+}
+
+func validateUpgradeOfSamples(oldFile, newFile string) error {
+	dir := getSamplesDir() + "/update-validation-test"
+	oldZipBytes, err := createZipWithComposeFile(dir, oldFile)
+	if err != nil {
+		return err
+	}
+	newZipBytes, err := createZipWithComposeFile(dir, newFile)
+	if err != nil {
+		return err
+	}
+	return ValidateUpgrade(oldZipBytes, newZipBytes, maintainerName, "sample")
+}
@@ -0,0 +1,98 @@
+package validation
+
+import (
+	"github.com/ocelot-cloud/shared/compose"
+	"gopkg.in/yaml.v3"
+	"strings"
+)
+
+// Platform variables which can be referenced in the docker-compose.yml of an app, like ${HOST}. They are substituted
+// when the file is completed for an installation.
+const (
+	VariableHost         = "HOST"
+	VariableAppUrl       = "APP_URL"
+	VariableMaintainer   = "MAINTAINER"
+	VariableAppName      = "APP_NAME"
+	VariableTimezone     = "TIMEZONE"
+	SecretVariablePrefix = "SECRET_"
+
+	defaultTimezone = "Etc/UTC"
+)
+
+var platformVariables = []string{VariableHost, VariableAppUrl, VariableMaintainer, VariableAppName, VariableTimezone}
+
+var (
+	unknownVariable          = "unknown variable '%s' referenced in docker-compose.yml, allowed are %s and %s<name> for secrets declared in app.yml"
+	invalidVariableReference = "invalid variable reference in docker-compose.yml: %v"
+)
+
+// Installation holds the values of the platform variables for one installed app.
+type Installation struct {
+	Maintainer string
+	AppName    string
+	Host       string
+	// Timezone is the IANA name of the timezone of the installation, like "Europe/Berlin". It defaults to "Etc/UTC".
+	Timezone string
+	// Secrets are the generated secrets of the installation by name, they are referenced as ${SECRET_<name>}.
+	Secrets map[string]string
+}
+
+// variables returns the values of all platform variables. The manifest may be nil.
+func (i Installation) variables(manifest *AppManifest) map[string]string {
+	timezone := i.Timezone
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+	variables := map[string]string{
+		VariableHost:       i.Host,
+		VariableAppUrl:     NewRoutingConfig(i.Maintainer, i.AppName, i.Host, manifest).URL(),
+		VariableMaintainer: i.Maintainer,
+		VariableAppName:    i.AppName,
+		VariableTimezone:   timezone,
+	}
+	for name, value := range i.Secrets {
+		variables[SecretVariablePrefix+name] = value
+	}
+	return variables
+}
+
+func (v *composeValidator) isKnownVariable(name string) bool {
+	return contains(platformVariables, name) || isDeclaredSecret(v.manifest, name)
+}
+
+// validateVariables checks that all values of the docker-compose.yml only reference platform variables.
+func (v *composeValidator) validateVariables() {
+	v.validateVariablesInNode(v.document, nil)
+}
+
+func (v *composeValidator) validateVariablesInNode(node *yaml.Node, path []string) {
+	serviceName := ""
+	if len(path) > 1 && path[0] == "services" {
+		serviceName = path[1]
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return
+		}
+		position := compose.Position{Line: node.Line, Column: node.Column}
+		names, err := compose.ReferencedVariables(node.Value)
+		if err != nil {
+			v.addError(RuleVariable, serviceName, position, invalidVariableReference, err)
+			return
+		}
+		for _, name := range names {
+			if !v.isKnownVariable(name) {
+				v.addError(RuleVariable, serviceName, position, unknownVariable, name, strings.Join(platformVariables, ", "), SecretVariablePrefix)
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v.validateVariablesInNode(node.Content[i+1], append(path, node.Content[i].Value))
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			v.validateVariablesInNode(child, path)
+		}
+	}
+}
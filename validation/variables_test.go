@@ -0,0 +1,49 @@
+package validation
+
+import (
+	"github.com/ocelot-cloud/shared/assert"
+	"github.com/ocelot-cloud/shared/compose"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompleteDockerComposeYamlForInstallation(t *testing.T) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, composeFileName)
+	assert.Nil(t, copyFile(getSamplesComposeDir()+"/platform-variables.yml", composePath))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, appYamlFileName), []byte("url_path: /explore\nport: 3000"), 0600))
+	installation := Installation{
+		Maintainer: "samplemaintainer",
+		AppName:    "gitea",
+		Host:       "my-domain.com",
+		Timezone:   "Europe/Berlin",
+	}
+	assert.Nil(t, CompleteDockerComposeYamlForInstallation(composePath, installation, DefaultHardeningProfile()))
+
+	project, err := compose.Load(composePath)
+	assert.Nil(t, err)
+	expected := []string{
+		"DOMAIN=my-domain.com",
+		"ROOT_URL=https://my-domain.com/explore",
+		"APP_ID=samplemaintainer-gitea",
+		"TZ=Europe/Berlin",
+		"PRICE=5$$",
+	}
+	var actual []string
+	for _, variable := range project.Services["gitea"].Environment.Variables {
+		actual = append(actual, variable.Name+"="+*variable.Value)
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestInstallationVariableDefaults(t *testing.T) {
+	variables := Installation{Maintainer: "samplemaintainer", AppName: "gitea", Host: "my-domain.com"}.variables(nil)
+	assert.Equal(t, map[string]string{
+		VariableHost:       "my-domain.com",
+		VariableAppUrl:     "https://my-domain.com/",
+		VariableMaintainer: "samplemaintainer",
+		VariableAppName:    "gitea",
+		VariableTimezone:   "Etc/UTC",
+	}, variables)
+}
@@ -1,43 +1,60 @@
 package validation
 
 import (
-	"archive/zip"
 	"bytes"
 	"errors"
 	"fmt"
+	"github.com/ocelot-cloud/shared/compose"
 	"github.com/ocelot-cloud/shared/utils"
 	"gopkg.in/yaml.v3"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 )
 
+// maxVersionFileSize limits the size of each file of an app version.
+const maxVersionFileSize = 1024 * 1024
+
 var (
 	samplesDir        string
 	samplesComposeDir string
 
+	versionFileTooLarge = "file %s is larger than %d bytes"
+
 	notAllowedTopLevelKeyword                 = "not allowed root keyword in docker-compose.yml: %s"
 	notAllowedKeyInService                    = "not allowed key in service '%s': %s"
 	notAllowedMountingHostDirectories         = "host directories are mounted in service '%s' which is forbidden"
 	emptyDockerComposeIsNotAllowed            = "empty docker-compose.yml is not allowed"
 	mainServiceMustBeDefined                  = "there must be a service with the name: %s"
-	mainServiceNeedsContainerNameKeyword      = "service '%s' must have 'container_name' keyword"
 	mainServiceNeedsCorrectContainerNameValue = "service '%s' must have the container_name '%s'"
-	notAllowedMissingDockerImageTag           = "the image tag must consist of an image name and a tag separated by a colon, like 'gitea/gitea:10.5', but got: %s"
+	notAllowedMissingDockerImageTag           = "the image must have a tag separated by a colon, like 'gitea/gitea:10.5', but got: %s"
 	notAllowedLatestDockerImageTag            = "the 'latest' tag is forbidden, to get reproducible apps, only fixed tags with specific software version should be used"
+	notAllowedMutableDockerImageTag           = "the '%s' tag of image '%s' is forbidden, to get reproducible apps, only fixed tags with specific software version should be used"
+	imageDigestRequired                       = "image '%s' must be pinned by a digest, like 'gitea/gitea:10.5@sha256:...'"
+	notAllowedImageRegistry                   = "registry '%s' of image '%s' is not allowed, allowed registries are: %s"
+	invalidImageReference                     = "invalid image in service '%s': %v"
 	notAllowedExposingDefaultHttpPorts        = "exposing port %s is forbidden, as it is reserved for Ocelot-Cloud"
 	wrongVolumeNamePrefix                     = "volume names must start with '%s'"
+	invalidPortInService                      = "invalid port definition in service %v: %v"
+	invalidVolumeInService                    = "invalid volume entry in service %v: %v"
+	anonymousVolumeInService                  = "anonymous volume '%s' in service '%s' is not allowed, use a named volume starting with '%s'"
+	notAllowedVolumeType                      = "volume type '%s' in service '%s' is not allowed, only named volumes and tmpfs mounts are allowed"
 	ocelotCloudAppAlreadyReserved             = "app names 'ocelotcloud' and 'ocelotdb' are not allowed"
 	devicesKeywordIsForbidden                 = "'devices' keyword is not allowed"
 	deployKeywordMustOnlyContainResources     = "'deploy' keyword must only contain 'resources' keyword"
 	globalVolumeShouldNotHaveSubKeywords      = "volume has sub-keywords, which are not allowed"
 	wrongContainerNamePrefix                  = "the container names must have the prefix: %s"
 	containerNameMissing                      = "every service needs to have a 'container_name' keyword"
+	undefinedVolumeInService                  = "service '%s' refers to volume '%s', which is not defined in the top-level 'volumes'"
+	undefinedNetworkInService                 = "service '%s' refers to network '%s', which is not defined in the top-level 'networks'"
+	undefinedServiceDependency                = "service '%s' depends on service '%s', which is not defined"
 )
 
 func getSamplesDir() string {
@@ -54,101 +71,167 @@ func getSamplesComposeDir() string {
 	return samplesComposeDir
 }
 
+// ValidationOptions configures the rules applied by ValidateVersionWithOptions.
+type ValidationOptions struct {
+	// Policy defines the allowed keys, reserved ports, image rules and enabled rules. The DefaultPolicy is used if it is nil.
+	Policy *Policy
+	// DockerComposeCheck additionally runs 'docker compose config' on valid files, which requires the docker CLI.
+	DockerComposeCheck bool
+}
+
+func DefaultValidationOptions() ValidationOptions {
+	return ValidationOptions{
+		Policy: DefaultPolicy(),
+	}
+}
+
+func (o ValidationOptions) policy() *Policy {
+	if o.Policy == nil {
+		return DefaultPolicy()
+	}
+	return o.Policy
+}
+
 func ValidateVersion(zipBytes []byte, maintainerName, appName string) error {
-	if appName == "ocelotcloud" || appName == "ocelotdb" {
-		return errors.New(ocelotCloudAppAlreadyReserved)
+	return ValidateVersionWithOptions(zipBytes, maintainerName, appName, DefaultValidationOptions())
+}
+
+func ValidateVersionWithOptions(zipBytes []byte, maintainerName, appName string, options ValidationOptions) error {
+	return findingsToError(DiagnoseVersion(zipBytes, maintainerName, appName, options))
+}
+
+// DiagnoseVersion validates all files of an app version and returns every problem found instead of stopping at the first one.
+func DiagnoseVersion(zipBytes []byte, maintainerName, appName string, options ValidationOptions) []Finding {
+	r := &report{}
+	if isReservedAppName(appName) {
+		r.addError(RuleReservedAppName, "", errors.New(ocelotCloudAppAlreadyReserved))
+		return r.findings
+	}
+	fsys, err := utils.OpenZip(zipBytes)
+	if err != nil {
+		r.addError(RuleArchive, "", err)
+		return r.findings
+	}
+	return DiagnoseVersionFS(fsys, maintainerName, appName, options)
+}
+
+// ValidateVersionFS validates the files of an app version like ValidateVersionWithOptions. The files are read from
+// the file system only, so that an archive opened with utils.OpenZip is validated without touching the disk.
+func ValidateVersionFS(fsys fs.FS, maintainerName, appName string, options ValidationOptions) error {
+	return findingsToError(DiagnoseVersionFS(fsys, maintainerName, appName, options))
+}
+
+// DiagnoseVersionFS returns the findings of all checks like DiagnoseVersion for the files of an app version in fsys.
+func DiagnoseVersionFS(fsys fs.FS, maintainerName, appName string, options ValidationOptions) []Finding {
+	r := &report{}
+	if isReservedAppName(appName) {
+		r.addError(RuleReservedAppName, "", errors.New(ocelotCloudAppAlreadyReserved))
+		return r.findings
 	}
 
-	tempDir, err := utils.UnzipToTempDir(zipBytes)
+	hasDockerCompose, manifest := validateFiles(r, fsys)
+	validateCapabilityRequests(r, manifest, options.policy())
+	if !hasDockerCompose {
+		return r.findings
+	}
+	data, err := readVersionFile(fsys, composeFileName)
 	if err != nil {
-		return err
+		r.addError(RuleComposeSyntax, composeFileName, fmt.Errorf("failed to read docker-compose.yml: %v", err))
+		return r.findings
 	}
-	defer utils.RemoveDir(tempDir)
+	parseAndValidateComposeFile(r, data, maintainerName, appName, manifest, options)
+	if r.hasErrors() || !options.DockerComposeCheck {
+		return r.findings
+	}
+	if err := checkDockerComposeSyntax(data); err != nil {
+		r.addError(RuleComposeConsistency, composeFileName, err)
+	}
+	return r.findings
+}
 
-	if err := validateFilesInDir(tempDir); err != nil {
-		return err
+func isReservedAppName(appName string) bool {
+	return appName == "ocelotcloud" || appName == "ocelotdb"
+}
+
+// readVersionFile reads a file of an app version. The size is limited, since the file system may be backed by an
+// untrusted archive.
+func readVersionFile(fsys fs.FS, name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
 	}
-	composePath := filepath.Join(tempDir, "docker-compose.yml")
-	if err := parseAndValidateComposeFile(composePath, maintainerName, appName); err != nil {
-		return err
+	defer utils.Close(file)
+	data, err := io.ReadAll(io.LimitReader(file, maxVersionFileSize+1))
+	if err != nil {
+		return nil, err
 	}
-	if err := checkDockerComposeSyntax(composePath); err != nil {
-		return err
+	if len(data) > maxVersionFileSize {
+		return nil, fmt.Errorf(versionFileTooLarge, name, maxVersionFileSize)
 	}
-	return nil
+	return data, nil
 }
 
-func validateFilesInDir(dir string) error {
-	files, err := os.ReadDir(dir)
+// validateFiles checks the files of the version and tells whether a docker-compose.yml is present. The returned
+// manifest is nil if there is no app.yml or it could not be parsed.
+func validateFiles(r *report, fsys fs.FS) (bool, *AppManifest) {
+	files, err := fs.ReadDir(fsys, ".")
 	if err != nil {
-		return fmt.Errorf("failed to read temp dir: %v", err)
+		r.addError(RuleArchive, "", fmt.Errorf("failed to read files of version: %v", err))
+		return false, nil
 	}
 
 	if len(files) == 0 {
-		return fmt.Errorf("zip file is empty")
+		r.addError(RuleArchive, "", fmt.Errorf("zip file is empty"))
+		return false, nil
+	}
+
+	var manifest *AppManifest
+	iconFileName := ""
+	for _, file := range files {
+		if file.Name() == appYamlFileName && !file.IsDir() {
+			if manifest = validateAppYaml(r, fsys, appYamlFileName); manifest != nil {
+				iconFileName = manifest.Icon
+			}
+		}
 	}
 
 	hasDockerCompose := false
+	hasIcon := false
 
 	for _, file := range files {
-		if file.IsDir() {
-			return fmt.Errorf("directories are not allowed in the zip file: %s", file.Name())
-		}
 		fname := file.Name()
-		if fname == "docker-compose.yml" {
+		if file.IsDir() {
+			r.addError(RuleArchive, fname, fmt.Errorf("directories are not allowed in the zip file: %s", fname))
+		} else if fname == composeFileName {
 			hasDockerCompose = true
-		} else if fname == "app.yml" {
-			err = checkAppYamlCorrectness(dir + "/" + fname)
-			if err != nil {
-				return err
-			}
+		} else if fname == appYamlFileName {
+			continue
+		} else if fname == iconFileName {
+			hasIcon = true
+			validateIconFile(r, fsys, fname)
 		} else {
-			return fmt.Errorf("unexpected file in zip: %s", fname)
+			r.addError(RuleArchive, fname, fmt.Errorf("unexpected file in zip: %s", fname))
 		}
 	}
 
+	if iconFileName != "" && !hasIcon {
+		r.addError(RuleArchive, appYamlFileName, fmt.Errorf(iconFileMissing, iconFileName))
+	}
+
 	if !hasDockerCompose {
-		return fmt.Errorf("docker-compose.yml file is missing in zip")
+		r.addError(RuleArchive, "", fmt.Errorf("docker-compose.yml file is missing in zip"))
 	}
 
-	return nil
+	return hasDockerCompose, manifest
 }
 
-func checkAppYamlCorrectness(filePath string) error {
-	data, err := os.ReadFile(filePath) // #nosec G304 (CWE-22): Potential file inclusion via variable; no problem since it is called internally
+func validateAppYaml(r *report, fsys fs.FS, name string) *AppManifest {
+	data, err := readVersionFile(fsys, name)
 	if err != nil {
-		return fmt.Errorf("failed to read app.yml: %v", err)
-	}
-
-	var appConfig map[string]interface{}
-	if err := yaml.Unmarshal(data, &appConfig); err != nil {
-		return fmt.Errorf("failed to parse app.yml: %v", err)
-	}
-
-	allowedKeys := map[string]bool{
-		"url_path": true,
-		"port":     true,
-	}
-
-	for key := range appConfig {
-		if !allowedKeys[key] {
-			return fmt.Errorf("not allowed key in app.yml: %s", key)
-		}
-	}
-
-	if urlPath, ok := appConfig["url_path"].(string); ok {
-		if !IsValidURLPath(urlPath) {
-			return fmt.Errorf("invalid url_path in app.yml: %s", urlPath)
-		}
-	}
-
-	if port, ok := appConfig["port"].(int); ok {
-		if port < 1 || port > 65535 {
-			return fmt.Errorf("invalid port in app.yml: %d", port)
-		}
+		r.addError(RuleAppYaml, appYamlFileName, fmt.Errorf("failed to read app.yml: %v", err))
+		return nil
 	}
-
-	return nil
+	return parseAppManifest(r, data)
 }
 
 var re = regexp.MustCompile(`^/[a-zA-Z0-9_-]{0,100}$`)
@@ -157,8 +240,18 @@ func IsValidURLPath(path string) bool {
 	return re.MatchString(path)
 }
 
-func checkDockerComposeSyntax(composePath string) error {
-	cmd := exec.Command("docker", "compose", "-f", composePath, "config")
+// checkDockerComposeSyntax runs 'docker compose config' on the file, which needs to be written to a temporary directory.
+func checkDockerComposeSyntax(data []byte) error {
+	tempDir, err := os.MkdirTemp("", "compose")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %v", err)
+	}
+	defer utils.RemoveDir(tempDir)
+	composePath := filepath.Join(tempDir, composeFileName)
+	if err := os.WriteFile(composePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write docker-compose.yml: %v", err)
+	}
+	cmd := exec.Command("docker", "compose", "-f", composePath, "config") // #nosec G204 (CWE-78): Subprocess launched with variable; the path is a temporary file created above
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
@@ -170,405 +263,395 @@ func checkDockerComposeSyntax(composePath string) error {
 	}
 	return nil
 }
-func parseAndValidateComposeFile(composePath, maintainerName, appName string) error {
-	compose, err := readComposeFile(composePath)
-	if err != nil {
-		return err
+
+// composeValidator collects the findings of all rules applied to a parsed docker-compose.yml.
+type composeValidator struct {
+	report         *report
+	project        *compose.Project
+	document       *yaml.Node
+	manifest       *AppManifest
+	maintainerName string
+	appName        string
+	options        ValidationOptions
+	policy         *Policy
+}
+
+func parseAndValidateComposeFile(r *report, data []byte, maintainerName, appName string, manifest *AppManifest, options ValidationOptions) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		r.addErrorAt(RuleComposeSyntax, composeFileName, yamlErrorLine(err), fmt.Errorf("failed to parse docker-compose.yml: %v", err))
+		return
 	}
-	if err := validateTopLevelKeys(compose); err != nil {
-		return err
+	if countExpandedNodes(&document, maxComposeNodes) > maxComposeNodes {
+		r.addError(RuleComposeSyntax, composeFileName, fmt.Errorf(composeFileTooComplex, maxComposeNodes))
+		return
 	}
-	if err := validateServices(compose, maintainerName, appName); err != nil {
-		return err
+	matchesSchema := validateComposeSchema(r, &document)
+	project, err := parseComposeData(data)
+	if err != nil {
+		// type errors of the parser are already reported with better messages by the schema validation
+		if matchesSchema {
+			r.addErrorAt(RuleComposeSyntax, composeFileName, yamlErrorLine(err), err)
+		}
+		return
 	}
-	if err := validateGlobalVolumes(compose); err != nil {
-		return err
+	v := &composeValidator{
+		report:         r,
+		project:        project,
+		document:       &document,
+		manifest:       manifest,
+		maintainerName: maintainerName,
+		appName:        appName,
+		options:        options,
+		policy:         options.policy(),
+	}
+	v.validateTopLevelKeys()
+	v.validateServices()
+	v.validateGlobalVolumes()
+	v.validateReferences()
+	v.validateVariables()
+}
+
+// validateComposeSchema reports all violations of the compose specification and tells whether there are none.
+func validateComposeSchema(r *report, document *yaml.Node) bool {
+	violations := validateAgainstSchema(composeSpecSchema, document)
+	for _, violation := range violations {
+		serviceName := ""
+		if len(violation.path) > 1 && violation.path[0] == "services" {
+			serviceName = violation.path[1]
+		}
+		position := compose.Position{Line: violation.node.Line, Column: violation.node.Column}
+		r.addComposeFinding(RuleComposeSchema, SeverityError, serviceName, position, fmt.Sprintf(composeSchemaViolation, violation.pathString(), violation.message))
 	}
-	return nil
+	return len(violations) == 0
 }
 
-func readComposeFile(composePath string) (map[string]interface{}, error) {
-	data, err := os.ReadFile(composePath) // #nosec G304 (CWE-22): Potential file inclusion via variable; no problem since it is called internally
+func parseComposeData(data []byte) (*compose.Project, error) {
+	project, err := compose.Parse(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read docker-compose.yml: %v", err)
-	}
-	var c map[string]interface{}
-	if err := yaml.Unmarshal(data, &c); err != nil {
-		return nil, fmt.Errorf("failed to parse docker-compose.yml: %v", err)
+		return nil, fmt.Errorf("failed to parse docker-compose.yml: %w", err)
 	}
-	if len(c) == 0 {
+	if project.IsEmpty() {
 		return nil, errors.New(emptyDockerComposeIsNotAllowed)
 	}
-	return c, nil
+	return project, nil
 }
 
-func validateTopLevelKeys(compose map[string]interface{}) error {
-	allowed := map[string]bool{"volumes": true, "services": true}
-	for k := range compose {
-		if !allowed[k] {
-			return fmt.Errorf(notAllowedTopLevelKeyword, k)
+func (v *composeValidator) addError(ruleID, serviceName string, position compose.Position, message string, args ...interface{}) {
+	v.addFinding(ruleID, SeverityError, serviceName, position, message, args...)
+}
+
+func (v *composeValidator) addFinding(ruleID string, severity Severity, serviceName string, position compose.Position, message string, args ...interface{}) {
+	if !v.policy.IsRuleEnabled(ruleID) {
+		return
+	}
+	v.report.addComposeFinding(ruleID, severity, serviceName, position, fmt.Sprintf(message, args...))
+}
+
+func (v *composeValidator) servicePosition(serviceName string, path ...string) compose.Position {
+	return v.project.Position(append([]string{"services", serviceName}, path...)...)
+}
+
+func (v *composeValidator) validateTopLevelKeys() {
+	for _, k := range v.project.Keys() {
+		if !contains(v.policy.AllowedTopLevelKeys, k) {
+			v.addError(RuleTopLevelKey, "", v.project.Position(k), notAllowedTopLevelKeyword, k)
 		}
 	}
-	return nil
 }
 
-func validateServices(compose map[string]interface{}, maintainerName, appName string) error {
-	services, ok := compose["services"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid 'services' section in docker-compose.yml")
+func (v *composeValidator) validateServices() {
+	if v.project.Services == nil {
+		v.addError(RuleComposeSyntax, "", v.project.Position("services"), "invalid 'services' section in docker-compose.yml")
+		return
 	}
 	isMainServicePresent := false
-	for serviceName, serviceValue := range services {
-		serviceMap, ok := serviceValue.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("invalid service definition for service %v", serviceName)
-		}
-		if err := validateServiceKeys(serviceName, serviceMap); err != nil {
-			return err
-		}
-		if err := validateImage(serviceName, serviceMap); err != nil {
-			return err
-		}
-		if err := validateContainerName(serviceMap, maintainerName, appName); err != nil {
-			return err
-		}
-		if serviceName == appName {
+	for _, serviceName := range v.project.ServiceNames() {
+		service := v.project.Services[serviceName]
+		if serviceName == v.appName {
 			isMainServicePresent = true
-			if err := validateMainServiceContainerName(serviceName, serviceMap, maintainerName, appName); err != nil {
-				return err
-			}
-		}
-		if err := validatePorts(serviceName, serviceMap); err != nil {
-			return err
-		}
-		if err := validateServiceVolumes(maintainerName, appName, serviceName, serviceMap); err != nil {
-			return err
-		}
-		if err := validateServiceNetworks(serviceName, serviceMap); err != nil {
-			return err
-		}
-		if err := validateDeploySection(serviceName, serviceMap); err != nil {
-			return err
 		}
+		v.validateServiceKeys(serviceName, service)
+		v.validateImage(serviceName, service)
+		v.validateContainerName(serviceName, service)
+		v.validatePorts(serviceName, service)
+		v.validateServiceVolumes(serviceName, service)
+		v.validateServiceNetworks(serviceName, service)
+		v.validateDeploySection(serviceName, service)
+		v.validateEnvironment(serviceName, service)
+		v.validateCredentials(serviceName, service)
+		v.validateHealthcheck(serviceName, service)
 	}
 	if !isMainServicePresent {
-		return fmt.Errorf(mainServiceMustBeDefined, appName)
+		v.addError(RuleMainService, "", v.project.Position("services"), mainServiceMustBeDefined, v.appName)
 	}
-	return nil
 }
 
-func validateServiceKeys(serviceName string, serviceMap map[string]interface{}) error {
-	allowed := []string{"image", "container_name", "ports", "volumes", "depends_on", "environment", "deploy", "tmpfs", "tty", "user", "command", "entrypoint"}
-	for k := range serviceMap {
-		found := false
-		for _, a := range allowed {
-			if k == a {
-				found = true
-				break
-			}
+func (v *composeValidator) validateServiceKeys(serviceName string, service *compose.Service) {
+	for _, k := range service.Keys() {
+		if contains(v.policy.AllowedServiceKeys, k) {
+			continue
 		}
-		if !found {
-			return fmt.Errorf(notAllowedKeyInService, serviceName, k)
+		if k == "env_file" {
+			v.addError(RuleEnvironment, serviceName, v.servicePosition(serviceName, k), envFileNotAllowed, serviceName)
+		} else {
+			v.addError(RuleServiceKey, serviceName, v.servicePosition(serviceName, k), notAllowedKeyInService, serviceName, k)
 		}
 	}
-	return nil
 }
 
-func validateImage(serviceName string, serviceMap map[string]interface{}) error {
-	img, ok := serviceMap["image"]
-	if !ok {
-		return fmt.Errorf("service '%s' must have 'image' keyword", serviceName)
+func (v *composeValidator) validateImage(serviceName string, service *compose.Service) {
+	if err := validateImage(serviceName, service, v.policy.ImagePolicy); err != nil {
+		v.addError(RuleImage, serviceName, v.servicePosition(serviceName, "image"), "%s", err.Error())
 	}
-	parts := strings.Split(img.(string), ":")
-	if len(parts) < 2 {
-		return fmt.Errorf(notAllowedMissingDockerImageTag, img)
-	}
-	if parts[1] == "latest" {
-		return errors.New(notAllowedLatestDockerImageTag)
-	}
-	return nil
 }
 
-func validateContainerName(serviceMap map[string]interface{}, maintainerName, appName string) error {
-	cn, ok := serviceMap["container_name"]
-	if !ok {
-		return errors.New(containerNameMissing)
+func validateImage(serviceName string, service *compose.Service, policy ImagePolicy) error {
+	if service.Image == "" {
+		return fmt.Errorf("service '%s' must have 'image' keyword", serviceName)
 	}
-	prefix := fmt.Sprintf("%s_%s_", maintainerName, appName)
-	if !strings.HasPrefix(cn.(string), prefix) {
-		return fmt.Errorf(wrongContainerNamePrefix, prefix)
+	ref, err := ParseImageReference(service.Image)
+	if err != nil {
+		return fmt.Errorf(invalidImageReference, serviceName, err)
 	}
-	return nil
+	return policy.check(ref, service.Image)
 }
 
-func validateMainServiceContainerName(serviceName string, serviceMap map[string]interface{}, maintainerName, appName string) error {
-	acn, ok := serviceMap["container_name"]
-	if !ok {
-		return fmt.Errorf(mainServiceNeedsContainerNameKeyword, serviceName)
+func (v *composeValidator) validateContainerName(serviceName string, service *compose.Service) {
+	position := v.servicePosition(serviceName, "container_name")
+	if service.ContainerName == "" {
+		v.addError(RuleContainerName, serviceName, position, "%s", containerNameMissing)
+		return
 	}
-	expected := fmt.Sprintf("%s_%s_%s", maintainerName, appName, appName)
-	if acn != expected {
-		return fmt.Errorf(mainServiceNeedsCorrectContainerNameValue, serviceName, expected)
+	if serviceName == v.appName {
+		expected := fmt.Sprintf("%s_%s_%s", v.maintainerName, v.appName, v.appName)
+		if service.ContainerName != expected {
+			v.addError(RuleContainerName, serviceName, position, mainServiceNeedsCorrectContainerNameValue, serviceName, expected)
+		}
+		return
+	}
+	prefix := fmt.Sprintf("%s_%s_", v.maintainerName, v.appName)
+	if !strings.HasPrefix(service.ContainerName, prefix) {
+		v.addError(RuleContainerName, serviceName, position, wrongContainerNamePrefix, prefix)
 	}
-	return nil
 }
 
-func validatePorts(serviceName string, serviceMap map[string]interface{}) error {
-	p, ok := serviceMap["ports"]
-	if !ok {
-		return nil
-	}
-	for _, port := range p.([]interface{}) {
-		ps, ok := port.(string)
-		if !ok {
-			return fmt.Errorf("invalid port definition in service %v", serviceName)
+func (v *composeValidator) validatePorts(serviceName string, service *compose.Service) {
+	for i, port := range service.Ports {
+		position := v.servicePosition(serviceName, "ports", strconv.Itoa(i))
+		mapping, err := port.Mapping()
+		if err != nil {
+			v.addError(RuleReservedPort, serviceName, position, invalidPortInService, serviceName, err)
+			continue
 		}
-		fields := strings.Split(ps, ":")
-		if fields[0] == "53" || fields[0] == "80" || fields[0] == "443" {
-			return fmt.Errorf(notAllowedExposingDefaultHttpPorts, fields[0])
+		for _, reserved := range v.policy.ReservedPorts {
+			if mapping.Published.Contains(reserved) {
+				v.addError(RuleReservedPort, serviceName, position, notAllowedExposingDefaultHttpPorts, strconv.Itoa(reserved))
+			}
 		}
 	}
-	return nil
 }
 
-func validateDeploySection(serviceName string, serviceMap map[string]interface{}) error {
-	d, has := serviceMap["deploy"]
-	if !has {
-		return nil
-	}
-	dm, ok := d.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("'deploy' keyword in service '%s' must be a map", serviceName)
-	}
-	for dk := range dm {
-		if dk != "resources" {
-			return fmt.Errorf(deployKeywordMustOnlyContainResources, serviceName, dk)
-		}
-	}
-	rm, rok := dm["resources"].(map[string]interface{})
-	if !rok {
-		return nil
+func (v *composeValidator) validateDeploySection(serviceName string, service *compose.Service) {
+	if service.Deploy == nil {
+		return
 	}
-	res, hasRes := rm["reservations"]
-	if !hasRes {
-		return nil
+	if len(service.Deploy.Extra) > 0 {
+		v.addError(RuleDeploy, serviceName, v.servicePosition(serviceName, "deploy"), "%s", deployKeywordMustOnlyContainResources)
 	}
-	rsm, rok := res.(map[string]interface{})
-	if !rok {
-		return fmt.Errorf("'reservations' in 'resources' of service '%s' must be a map", serviceName)
+	resources := service.Deploy.Resources
+	if resources == nil || resources.Reservations == nil {
+		return
 	}
-	if _, hasDev := rsm["devices"]; hasDev {
-		return errors.New(devicesKeywordIsForbidden)
+	if len(resources.Reservations.Devices) > 0 {
+		v.addError(RuleDeviceReservation, serviceName, v.servicePosition(serviceName, "deploy", "resources", "reservations", "devices"), "%s", devicesKeywordIsForbidden)
 	}
-	return nil
 }
 
-func validateGlobalVolumes(compose map[string]interface{}) error {
-	vm, ok := compose["volumes"].(map[string]interface{})
-	if !ok {
-		return nil
-	}
-	for _, v := range vm {
-		vmap, ok := v.(map[string]interface{})
-		if ok && len(vmap) > 0 {
-			return errors.New(globalVolumeShouldNotHaveSubKeywords)
+func (v *composeValidator) validateGlobalVolumes() {
+	for _, volumeName := range sortedKeys(v.project.Volumes) {
+		if !v.project.Volumes[volumeName].IsEmpty() {
+			v.addError(RuleGlobalVolume, "", v.project.Position("volumes", volumeName), "%s", globalVolumeShouldNotHaveSubKeywords)
 		}
 	}
-	return nil
 }
 
-func validateServiceVolumes(maintainerName, appName, serviceName string, serviceMap map[string]interface{}) error {
-	vList, err := extractVolumesList(serviceName, serviceMap)
-	if err != nil {
-		return err
-	}
-	for _, vol := range vList {
-		if err := validateVolumeEntry(maintainerName, appName, serviceName, vol); err != nil {
-			return err
+func (v *composeValidator) validateServiceVolumes(serviceName string, service *compose.Service) {
+	for i, volume := range service.Volumes {
+		position := v.servicePosition(serviceName, "volumes", strconv.Itoa(i))
+		mount, err := volume.Mount()
+		if err != nil {
+			v.addError(RuleVolumeMount, serviceName, position, invalidVolumeInService, serviceName, err)
+			continue
 		}
-	}
-	return nil
-}
-
-func extractVolumesList(serviceName string, serviceMap map[string]interface{}) ([]string, error) {
-	if serviceMap["volumes"] == nil {
-		return nil, nil
-	}
-	v, ok := serviceMap["volumes"].([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid 'volumes' in service %v", serviceName)
-	}
-	var result []string
-	for _, item := range v {
-		s, ok := item.(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid volume entry in service %v", serviceName)
+		if err := validateMount(v.maintainerName, v.appName, serviceName, mount); err != nil {
+			v.addError(RuleVolumeMount, serviceName, position, "%s", err.Error())
 		}
-		result = append(result, s)
 	}
-	return result, nil
 }
 
-func validateVolumeEntry(maintainerName, appName, serviceName, vol string) error {
-	parts := strings.Split(vol, ":")
-	if len(parts) < 2 {
-		return fmt.Errorf("invalid volume format in service %v: %s", serviceName, vol)
-	}
-	if filepath.IsAbs(parts[0]) || strings.HasPrefix(parts[0], ".") {
-		return fmt.Errorf(notAllowedMountingHostDirectories, serviceName)
-	}
+// validateMount only allows volumes belonging to the app and tmpfs mounts, so that apps can neither access the host
+// nor the data of other apps.
+func validateMount(maintainerName, appName, serviceName string, mount compose.Mount) error {
 	prefix := fmt.Sprintf("%s_%s_", maintainerName, appName)
-	if !strings.HasPrefix(vol, prefix) {
-		return fmt.Errorf(wrongVolumeNamePrefix, prefix)
+	switch mount.Type {
+	case compose.MountTypeTmpfs:
+		return nil
+	case compose.MountTypeBind:
+		return fmt.Errorf(notAllowedMountingHostDirectories, serviceName)
+	case compose.MountTypeVolume:
+		if mount.Source == "" {
+			return fmt.Errorf(anonymousVolumeInService, mount.Target, serviceName, prefix)
+		}
+		if !strings.HasPrefix(mount.Source, prefix) {
+			return fmt.Errorf(wrongVolumeNamePrefix, prefix)
+		}
+		return nil
+	default:
+		return fmt.Errorf(notAllowedVolumeType, mount.Type, serviceName)
 	}
-	return nil
 }
 
-func validateServiceNetworks(serviceName string, serviceMap map[string]interface{}) error {
-	if serviceMap["networks"] == nil {
-		return nil
+func (v *composeValidator) validateServiceNetworks(serviceName string, service *compose.Service) {
+	for _, network := range service.Networks {
+		if network == "host" {
+			v.addError(RuleHostNetwork, serviceName, v.servicePosition(serviceName, "networks"), "using host network is forbidden in service %v", serviceName)
+		}
 	}
-	return validateNetworksDefinition(serviceName, serviceMap["networks"])
 }
 
-func validateNetworksDefinition(serviceName string, networks interface{}) error {
-	switch n := networks.(type) {
-	case []interface{}:
-		for _, net := range n {
-			netStr, ok := net.(string)
-			if !ok {
-				return fmt.Errorf("invalid network entry in service %v", serviceName)
+// validateReferences checks that volumes, networks and dependencies used by the services are defined, which is
+// what 'docker compose config' would complain about beyond the schema.
+func (v *composeValidator) validateReferences() {
+	for _, serviceName := range v.project.ServiceNames() {
+		service := v.project.Services[serviceName]
+		for i, volume := range service.Volumes {
+			source := namedVolumeSource(volume)
+			if _, defined := v.project.Volumes[source]; source != "" && !defined {
+				v.addError(RuleComposeConsistency, serviceName, v.servicePosition(serviceName, "volumes", strconv.Itoa(i)), undefinedVolumeInService, serviceName, source)
 			}
-			if netStr == "host" {
-				return fmt.Errorf("using host network is forbidden in service %v", serviceName)
+		}
+		for _, network := range service.Networks {
+			if _, defined := v.project.Networks[network]; network != "default" && !defined {
+				v.addError(RuleComposeConsistency, serviceName, v.servicePosition(serviceName, "networks"), undefinedNetworkInService, serviceName, network)
 			}
 		}
-	case map[string]interface{}:
-		for netName := range n {
-			if netName == "host" {
-				return fmt.Errorf("using host network is forbidden in service %v", serviceName)
+		for _, dependency := range service.DependsOn.Services {
+			if _, defined := v.project.Services[dependency]; !defined {
+				v.addError(RuleComposeConsistency, serviceName, v.servicePosition(serviceName, "depends_on"), undefinedServiceDependency, serviceName, dependency)
 			}
 		}
-	default:
-		return fmt.Errorf("invalid 'networks' definition in service %v", serviceName)
 	}
-	return nil
 }
 
+// namedVolumeSource returns the name of the volume mounted by the entry, or an empty string for host directories,
+// tmpfs mounts and anonymous volumes.
+func namedVolumeSource(volume compose.ServiceVolume) string {
+	mount, err := volume.Mount()
+	if err != nil || mount.Type != compose.MountTypeVolume {
+		return ""
+	}
+	return mount.Source
+}
+
+// CompleteDockerComposeYaml adds the network, the default hardening and the proxy labels to the docker-compose.yml of
+// an app version and substitutes the platform variables, like ${HOST}.
 func CompleteDockerComposeYaml(maintainer, appName, filePath, host string) error {
-	composeMap, err := readCompose(filePath)
+	return CompleteDockerComposeYamlWithProfile(maintainer, appName, filePath, host, DefaultHardeningProfile())
+}
+
+// CompleteDockerComposeYamlWithProfile completes the docker-compose.yml like CompleteDockerComposeYaml, but applies
+// the given hardening profile to the services.
+func CompleteDockerComposeYamlWithProfile(maintainer, appName, filePath, host string, profile HardeningProfile) error {
+	installation := Installation{Maintainer: maintainer, AppName: appName, Host: host}
+	return CompleteDockerComposeYamlForInstallation(filePath, installation, profile)
+}
+
+// CompleteDockerComposeYamlForInstallation completes the docker-compose.yml with the values of the installation.
+// Capabilities requested in an app.yml next to the file are added if the profile allows them, its port and url_path
+// are used for the proxy labels of the main service and the APP_URL variable.
+func CompleteDockerComposeYamlForInstallation(filePath string, installation Installation, profile HardeningProfile) error {
+	project, err := compose.Load(filePath)
 	if err != nil {
 		return err
 	}
-	addExternalNetwork(composeMap, maintainer, appName)
-	updateServices(composeMap, maintainer, appName)
-	updateVolumes(composeMap)
-	return writeCompose(composeMap, filePath, host)
-}
-
-func readCompose(filePath string) (map[string]interface{}, error) {
-	b, err := os.ReadFile(filePath) // #nosec G304 (CWE-22): Potential file inclusion via variable; no problem since it is called internally
+	manifest, err := loadSiblingAppManifest(filePath)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var m map[string]interface{}
-	if err := yaml.Unmarshal(b, &m); err != nil {
-		return nil, err
+	if err := checkSecretValues(manifest, installation.Secrets); err != nil {
+		return err
 	}
-	return m, nil
+	capabilities, err := profile.capabilitiesToAdd(manifest)
+	if err != nil {
+		return err
+	}
+	maintainer, appName := installation.Maintainer, installation.AppName
+	addExternalNetwork(project, maintainer, appName)
+	updateServices(project, maintainer, appName, profile, capabilities)
+	routing := NewRoutingConfig(maintainer, appName, installation.Host, manifest)
+	addRoutingLabels(project, routing)
+	addReadinessHealthcheck(project, routing, manifest)
+	updateVolumes(project)
+	return writeCompose(project, filePath, installation.variables(manifest))
 }
 
-func addExternalNetwork(m map[string]interface{}, maintainer, appName string) {
-	net := fmt.Sprintf("%s_%s", maintainer, appName)
-	m["networks"] = map[string]interface{}{
-		net: map[string]interface{}{"external": true},
+// loadSiblingAppManifest loads the app.yml in the directory of the docker-compose.yml, or returns nil if there is none.
+func loadSiblingAppManifest(composePath string) (*AppManifest, error) {
+	manifestPath := filepath.Join(filepath.Dir(composePath), appYamlFileName)
+	if _, err := os.Stat(manifestPath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
 	}
+	return LoadAppManifest(manifestPath)
 }
 
-func updateServices(m map[string]interface{}, maintainer, appName string) {
-	svcs, _ := m["services"].(map[string]interface{})
+func addExternalNetwork(project *compose.Project, maintainer, appName string) {
 	net := fmt.Sprintf("%s_%s", maintainer, appName)
-	for k, v := range svcs {
-		vm, _ := v.(map[string]interface{})
-		vm["networks"] = []interface{}{net}
-		vm["restart"] = "unless-stopped"
-		vm["cap_drop"] = []interface{}{"ALL"}
-		vm["cap_add"] = []interface{}{
-			"CAP_NET_BIND_SERVICE", "CAP_CHOWN", "CAP_FOWNER",
-			"CAP_SETGID", "CAP_SETUID", "CAP_DAC_OVERRIDE",
-		}
-		svcs[k] = vm
+	project.Networks = map[string]*compose.Network{
+		net: {External: true},
 	}
-	m["services"] = svcs
 }
 
-func updateVolumes(m map[string]interface{}) {
-	volumes, _ := m["volumes"].(map[string]interface{})
-	if volumes == nil {
-		return
-	}
-	for volName, volVal := range volumes {
-		vm, ok := volVal.(map[string]interface{})
-		if !ok || vm == nil {
-			vm = make(map[string]interface{})
-		}
-		vm["name"] = volName
-		volumes[volName] = vm
+func updateServices(project *compose.Project, maintainer, appName string, profile HardeningProfile, capabilities []string) {
+	net := fmt.Sprintf("%s_%s", maintainer, appName)
+	for _, service := range project.Services {
+		service.Networks = compose.NetworkList{net}
+		profile.apply(service, capabilities)
 	}
-	m["volumes"] = volumes
 }
 
-func writeCompose(m map[string]interface{}, filePath, host string) error {
-	composeBytes, err := yaml.Marshal(m)
-	if err != nil {
-		return err
+func updateVolumes(project *compose.Project) {
+	for volName, volume := range project.Volumes {
+		if volume == nil {
+			volume = &compose.Volume{}
+		}
+		volume.Name = volName
+		project.Volumes[volName] = volume
 	}
-	content := strings.ReplaceAll(string(composeBytes), "${HOST}", host)
-	return os.WriteFile(filePath, []byte(content), 0600)
 }
 
-func ZipDirectory(dirPath string) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	zipWriter := zip.NewWriter(buf)
-
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
+// writeCompose substitutes the variables and writes the project. Secret references must not be left for compose, which
+// would fill them from the environment of the host.
+func writeCompose(project *compose.Project, filePath string, variables map[string]string) error {
+	unresolvedSecret := ""
+	composeBytes, err := project.MarshalSubstituted(func(name string) (string, bool) {
+		value, ok := variables[name]
+		if !ok && unresolvedSecret == "" && strings.HasPrefix(name, SecretVariablePrefix) {
+			unresolvedSecret = name
 		}
-
-		if info.IsDir() {
-			return nil
-		}
-
-		relPath, err := filepath.Rel(dirPath, path)
-		if err != nil {
-			return err
-		}
-
-		fileInZip, err := zipWriter.Create(relPath)
-		if err != nil {
-			return err
-		}
-
-		fsFile, err := os.Open(path) // #nosec G304 (CWE-22): Potential file inclusion via variable; no problem since it is called internally
-		if err != nil {
-			return err
-		}
-		defer utils.Close(fsFile)
-
-		_, err = io.Copy(fileInZip, fsFile)
-		return err
+		return value, ok
 	})
-
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	if err = zipWriter.Close(); err != nil {
-		return nil, err
+	if unresolvedSecret != "" {
+		return fmt.Errorf(unresolvedSecretReference, unresolvedSecret)
 	}
+	return os.WriteFile(filePath, composeBytes, 0600)
+}
 
-	return buf.Bytes(), nil
+func ZipDirectory(dirPath string) ([]byte, error) {
+	return utils.ZipDirectoryToBytes(dirPath)
 }
 
 func AssertYamlEquality(t *testing.T, a, b []byte) {
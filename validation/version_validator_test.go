@@ -1,6 +1,7 @@
 package validation
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/ocelot-cloud/shared/assert"
 	"github.com/ocelot-cloud/shared/utils"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 var appName = "gitea"
@@ -54,6 +56,23 @@ func TestExtraDir(t *testing.T) {
 	assert.Equal(t, "directories are not allowed in the zip file: hello", err.Error())
 }
 
+func TestValidateVersionFS(t *testing.T) {
+	composeBytes, err := os.ReadFile(getSamplesComposeDir() + "/sample-gitea.yml")
+	assert.Nil(t, err)
+	fsys := fstest.MapFS{composeFileName: {Data: composeBytes}}
+	assert.Nil(t, ValidateVersionFS(fsys, maintainerName, appName, DefaultValidationOptions()))
+
+	fsys[appYamlFileName] = &fstest.MapFile{Data: []byte("port: 123456")}
+	err = ValidateVersionFS(fsys, maintainerName, appName, DefaultValidationOptions())
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid port in app.yml: 123456", err.Error())
+
+	fsys[appYamlFileName] = &fstest.MapFile{Data: bytes.Repeat([]byte("#"), maxVersionFileSize+1)}
+	err = ValidateVersionFS(fsys, maintainerName, appName, DefaultValidationOptions())
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to read app.yml: "+fmt.Sprintf(versionFileTooLarge, appYamlFileName, maxVersionFileSize), err.Error())
+}
+
 func TestInvalidZipBytes(t *testing.T) {
 	zipBytes := []byte("hello")
 	err := ValidateVersion(zipBytes, maintainerName, appName)
@@ -84,7 +103,6 @@ func TestValidation(t *testing.T) {
 
 		{"not-allowed-service-keyword.yml", fmt.Sprintf(notAllowedKeyInService, "gitea", "privileged")},
 		{"host-network.yml", fmt.Sprintf(notAllowedKeyInService, "gitea", "network_mode")},
-		{"using-healthcheck.yml", fmt.Sprintf(notAllowedKeyInService, "gitea", "healthcheck")},
 		{"using-cap-drop.yml", fmt.Sprintf(notAllowedKeyInService, "gitea", "cap_drop")},
 		{"using-restart.yml", fmt.Sprintf(notAllowedKeyInService, "gitea", "restart")},
 
@@ -102,10 +120,17 @@ func TestValidation(t *testing.T) {
 		{"exposing-port-53.yml", fmt.Sprintf(notAllowedExposingDefaultHttpPorts, "53")},
 		{"exposing-port-80.yml", fmt.Sprintf(notAllowedExposingDefaultHttpPorts, "80")},
 		{"exposing-port-443.yml", fmt.Sprintf(notAllowedExposingDefaultHttpPorts, "443")},
+		{"exposing-port-80-with-host-ip.yml", fmt.Sprintf(notAllowedExposingDefaultHttpPorts, "80")},
+		{"exposing-port-443-in-range.yml", fmt.Sprintf(notAllowedExposingDefaultHttpPorts, "443")},
+		{"exposing-port-53-in-long-syntax.yml", fmt.Sprintf(notAllowedExposingDefaultHttpPorts, "53")},
+		{"long-syntax-ports-and-volumes.yml", ""},
 
-		{"docker-compose-consistency-check.yml", "docker-compose.yml consistency check failed: invalid compose project"},
+		{"docker-compose-consistency-check.yml", fmt.Sprintf(undefinedVolumeInService, "gitea", "samplemaintainer_gitea_wrong-name")},
 
 		{"wrong-volume-prefix.yml", fmt.Sprintf(wrongVolumeNamePrefix, expectedPrefix)},
+		{"long-syntax-wrong-volume-prefix.yml", fmt.Sprintf(wrongVolumeNamePrefix, expectedPrefix)},
+		{"long-syntax-bind-mount.yml", fmt.Sprintf(notAllowedMountingHostDirectories, "gitea")},
+		{"anonymous-volume.yml", fmt.Sprintf(anonymousVolumeInService, "/data", "gitea", expectedPrefix)},
 
 		{"not-resources-keyword-in-deploy.yml", deployKeywordMustOnlyContainResources},
 		{"devices-keyword-in-resources.yml", devicesKeywordIsForbidden},
@@ -119,6 +144,20 @@ func TestValidation(t *testing.T) {
 		{"side-service-missing-container-name.yml", containerNameMissing},
 		{"main-service-missing-container-name.yml", containerNameMissing},
 		{"main-service-wrong-container-name.yml", fmt.Sprintf(mainServiceNeedsCorrectContainerNameValue, "gitea", "samplemaintainer_gitea_gitea")},
+
+		{"image-is-a-map.yml", fmt.Sprintf(composeSchemaViolation, "services.gitea.image", "expected string, but got object")},
+		{"ports-is-a-map.yml", fmt.Sprintf(composeSchemaViolation, "services.gitea.ports", "expected array, but got object")},
+		{"container-name-is-a-list.yml", fmt.Sprintf(composeSchemaViolation, "services.gitea.container_name", "expected string, but got array")},
+		{"platform-variables.yml", ""},
+		{"hardcoded-password.yml", fmt.Sprintf(hardcodedCredential, "GITEA__database__PASSWD", "gitea")},
+		{"unknown-variable.yml", fmt.Sprintf(unknownVariable, "DOMAIN", "HOST, APP_URL, MAINTAINER, APP_NAME, TIMEZONE", "SECRET_")},
+		{"using-healthcheck.yml", ""},
+		{"healthcheck-interval-too-short.yml", fmt.Sprintf(invalidHealthcheck, "gitea", "'interval' must be between 5s and 10m0s, but got: 1s")},
+		{"healthcheck-invalid-test.yml", fmt.Sprintf(invalidHealthcheck, "gitea", "'test' must be a list starting with 'CMD' or 'CMD-SHELL' followed by the command, use 'disable: true' to disable the healthcheck")},
+		{"using-env-file.yml", fmt.Sprintf(envFileNotAllowed, "gitea")},
+		{"docker-host-in-environment.yml", fmt.Sprintf(notAllowedEnvironmentVariable, "DOCKER_HOST", "gitea")},
+		{"service-is-a-string.yml", fmt.Sprintf(composeSchemaViolation, "services.gitea", "expected object, but got string")},
+		{"nested-aliases.yml", fmt.Sprintf(composeFileTooComplex, maxComposeNodes)},
 	}
 
 	for _, tc := range testCases {
@@ -181,32 +220,6 @@ func TestCompleteDockerComposeYaml(t *testing.T) {
 	AssertYamlEquality(t, expectedBytes, actualBytes)
 }
 
-func TestCheckAppYamlCorrectness(t *testing.T) {
-	testCases := []struct {
-		file          string
-		expectedError string
-	}{
-		{"empty-is-valid", ""},
-		{"full-valid", ""},
-		{"port-out-of-range", "invalid port in app.yml: 123456"},
-		{"not-allowed-field", "not allowed key in app.yml: not_allowed_field"},
-		{"not-a-path", "invalid url_path in app.yml: <script>alert('XSS')</script>"},
-	}
-
-	for _, tc := range testCases {
-		tc := tc
-		t.Run(tc.file, func(t *testing.T) {
-			err := checkAppYamlCorrectness(getSamplesDir() + "/app-yamls/" + tc.file + ".yml")
-			if tc.expectedError == "" {
-				assert.Nil(t, err)
-			} else {
-				assert.NotNil(t, err)
-				assert.Equal(t, tc.expectedError, err.Error())
-			}
-		})
-	}
-}
-
 func TestIsValidURLPath(t *testing.T) {
 	tests := []struct {
 		path     string